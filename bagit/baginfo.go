@@ -0,0 +1,357 @@
+package bagit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// Reserved labels for bag-info.txt as defined by the BagIt spec
+const (
+	SourceOrganization        = "Source-Organization"
+	OrganizationAddress       = "Organization-Address"
+	ContactName               = "Contact-Name"
+	ContactPhone              = "Contact-Phone"
+	ContactEmail              = "Contact-Email"
+	ExternalDescription       = "External-Description"
+	BaggingDate               = "Bagging-Date"
+	ExternalIdentifier        = "External-Identifier"
+	BagSize                   = "Bag-Size"
+	PayloadOxum               = "Payload-Oxum"
+	BagGroupIdentifier        = "Bag-Group-Identifier"
+	BagCount                  = "Bag-Count"
+	InternalSenderIdentifier  = "Internal-Sender-Identifier"
+	InternalSenderDescription = "Internal-Sender-Description"
+)
+
+// baggingDateFormat is the YYYY-MM-DD format the spec requires for
+// Bagging-Date
+const baggingDateFormat = "2006-01-02"
+
+// Tag is a single label/value pair from a tag file such as bag-info.txt
+type Tag struct {
+	Label string
+	Value string
+}
+
+// BagInfo holds the metadata from a bag's "bag-info.txt". Tags are kept in
+// the order they're read or added, and labels may be repeated. Label lookups
+// are case-insensitive, but the original case is preserved when writing.
+//
+// The zero value is an empty BagInfo ready to use.
+type BagInfo struct {
+	tags []Tag
+}
+
+// Tags returns a copy of all label/value pairs in order
+func (bi *BagInfo) Tags() []Tag {
+	return append([]Tag(nil), bi.tags...)
+}
+
+// Len returns the number of tags, counting repeated labels separately
+func (bi *BagInfo) Len() int {
+	return len(bi.tags)
+}
+
+// Get returns the first value for the given label, or an empty string if the
+// label isn't present
+func (bi *BagInfo) Get(label string) string {
+	for _, t := range bi.tags {
+		if strings.EqualFold(t.Label, label) {
+			return t.Value
+		}
+	}
+	return ""
+}
+
+// GetAll returns all values for the given label in order
+func (bi *BagInfo) GetAll(label string) []string {
+	var values []string
+	for _, t := range bi.tags {
+		if strings.EqualFold(t.Label, label) {
+			values = append(values, t.Value)
+		}
+	}
+	return values
+}
+
+// Add appends a label/value pair, even if the label is already present
+func (bi *BagInfo) Add(label, value string) {
+	bi.tags = append(bi.tags, Tag{Label: label, Value: value})
+}
+
+// Set replaces any existing values for label with the given value. If the
+// label was already present, the new value takes the place of its first
+// occurrence; otherwise it is appended.
+func (bi *BagInfo) Set(label, value string) {
+	var tags []Tag
+	var found bool
+	for _, t := range bi.tags {
+		if !strings.EqualFold(t.Label, label) {
+			tags = append(tags, t)
+			continue
+		}
+		if !found {
+			tags = append(tags, Tag{Label: label, Value: value})
+			found = true
+		}
+	}
+	if !found {
+		tags = append(tags, Tag{Label: label, Value: value})
+	}
+	bi.tags = tags
+}
+
+// Delete removes all values for the given label
+func (bi *BagInfo) Delete(label string) {
+	var tags []Tag
+	for _, t := range bi.tags {
+		if !strings.EqualFold(t.Label, label) {
+			tags = append(tags, t)
+		}
+	}
+	bi.tags = tags
+}
+
+// SetBaggingDate sets Bagging-Date to the given time's date
+func (bi *BagInfo) SetBaggingDate(t time.Time) {
+	bi.Set(BaggingDate, t.Format(baggingDateFormat))
+}
+
+// BaggingDate parses and returns the Bagging-Date value. The returned time is
+// the zero time if the tag isn't present.
+func (bi *BagInfo) BaggingDate() (time.Time, error) {
+	var val = bi.Get(BaggingDate)
+	if val == "" {
+		return time.Time{}, nil
+	}
+	var t, err = time.Parse(baggingDateFormat, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid %s %q: %w", BaggingDate, val, err)
+	}
+	return t, nil
+}
+
+// SetPayloadOxum sets Payload-Oxum from the payload's total size in bytes and
+// its file count
+func (bi *BagInfo) SetPayloadOxum(octets, count int64) {
+	bi.Set(PayloadOxum, fmt.Sprintf("%d.%d", octets, count))
+}
+
+// PayloadOxum parses and returns the Payload-Oxum value: the payload's total
+// size in bytes and its file count. An error is returned if the tag is
+// missing or invalid.
+func (bi *BagInfo) PayloadOxum() (octets, count int64, err error) {
+	var val = bi.Get(PayloadOxum)
+	if val == "" {
+		return 0, 0, fmt.Errorf("no %s present", PayloadOxum)
+	}
+
+	var parts = strings.Split(val, ".")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid %s %q", PayloadOxum, val)
+	}
+	octets, err = strconv.ParseInt(parts[0], 10, 64)
+	if err == nil {
+		count, err = strconv.ParseInt(parts[1], 10, 64)
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid %s %q: %w", PayloadOxum, val, err)
+	}
+
+	return octets, count, nil
+}
+
+// validateLabel returns an error if the label can't be written to a tag file
+func validateLabel(label string) error {
+	if label == "" {
+		return fmt.Errorf("empty label")
+	}
+	if strings.TrimSpace(label) != label {
+		return fmt.Errorf("label %q has leading or trailing whitespace", label)
+	}
+	if strings.ContainsAny(label, ":\r\n") {
+		return fmt.Errorf("label %q contains a colon or line break", label)
+	}
+	return nil
+}
+
+// Write writes all tags to w in tag file format. Values containing line
+// breaks are written using continuation lines.
+func (bi *BagInfo) Write(w io.Writer) error {
+	for _, t := range bi.tags {
+		var err = validateLabel(t.Label)
+		if err != nil {
+			return err
+		}
+
+		var lines = strings.Split(strings.ReplaceAll(t.Value, "\r\n", "\n"), "\n")
+		_, err = fmt.Fprintf(w, "%s: %s\n", t.Label, lines[0])
+		for _, line := range lines[1:] {
+			if err == nil {
+				_, err = fmt.Fprintf(w, "  %s\n", line)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ParseBagInfo reads tag file data from r and returns the BagInfo it
+// describes. Lines starting with whitespace are continuations of the previous
+// tag's value, and are joined to it with a line break after stripping the
+// leading whitespace.
+func ParseBagInfo(r io.Reader) (*BagInfo, error) {
+	var tags, err = parseTags(r)
+	if err != nil {
+		return nil, err
+	}
+	return &BagInfo{tags: tags}, nil
+}
+
+// parseTags does the work of reading tag file data for both bag-info.txt and
+// bagit.txt
+func parseTags(r io.Reader) ([]Tag, error) {
+	var data, err = ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.TrimPrefix(data, utf8BOM)
+
+	var tags []Tag
+	var text = strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if len(tags) == 0 {
+				return nil, fmt.Errorf("continuation line with no preceding tag: %q", line)
+			}
+			var last = &tags[len(tags)-1]
+			last.Value += "\n" + strings.TrimSpace(line)
+			continue
+		}
+
+		var parts = strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tag line: %q", line)
+		}
+		tags = append(tags, Tag{Label: strings.TrimSpace(parts[0]), Value: strings.TrimSpace(parts[1])})
+	}
+
+	return tags, nil
+}
+
+func (b *Bag) bagInfoFilename() string {
+	return filepath.Join(b.root, "bag-info.txt")
+}
+
+// ReadBagInfo parses the bag's "bag-info.txt" into b.Info. The file is
+// optional, so if it doesn't exist b.Info is simply emptied.
+func (b *Bag) ReadBagInfo() error {
+	return b.readBagInfoFrom(ioutil.ReadFile)
+}
+
+// readBagInfoFrom is ReadBagInfo with a custom function for reading the file
+func (b *Bag) readBagInfoFrom(readFile func(string) ([]byte, error)) error {
+	b.Info = BagInfo{}
+
+	var fname = b.bagInfoFilename()
+	var data, err = readFile(fname)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read bag info %q: %w", fname, err)
+	}
+
+	var bi *BagInfo
+	bi, err = ParseBagInfo(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to parse bag info %q: %w", fname, err)
+	}
+	b.Info = *bi
+	return nil
+}
+
+// writeBagInfo writes b.Info to "bag-info.txt" if it has any tags. If
+// Payload-Oxum hasn't been set, it's computed from the payload files unless
+// the bag has fetch items of unknown length.
+func (b *Bag) writeBagInfo() error {
+	if b.Info.Len() == 0 {
+		return nil
+	}
+
+	if b.Info.Get(PayloadOxum) == "" {
+		var octets, known, err = b.payloadOctets()
+		if err != nil {
+			return err
+		}
+		if known {
+			b.Info.SetPayloadOxum(octets, int64(len(b.payloadSums())))
+		}
+	}
+
+	// Render the tags first so invalid data doesn't cost us the existing file:
+	// canceling a SafeFile removes its final path
+	var buf bytes.Buffer
+	var err = b.Info.Write(&buf)
+	if err != nil {
+		return fmt.Errorf("error writing bag info: %s", err)
+	}
+
+	var f = fileutil.NewSafeFile(b.bagInfoFilename())
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		f.Cancel()
+		return fmt.Errorf("error writing bag info: %s", err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("error writing bag info: %s", err)
+	}
+	return nil
+}
+
+// payloadOctets returns the total size of all files in b.ActualChecksums.
+// Fetch items which aren't on disk use their declared length, and if any of
+// those is unknown, known will be false.
+func (b *Bag) payloadOctets() (total int64, known bool, err error) {
+	var fetchLengths = make(map[string]int64)
+	for _, item := range b.FetchItems {
+		fetchLengths[item.Path] = item.Length
+	}
+
+	for _, ck := range b.payloadSums() {
+		var info, err = os.Stat(filepath.Join(b.root, ck.Path))
+		if err == nil {
+			total += info.Size()
+			continue
+		}
+
+		var length, isFetch = fetchLengths[ck.Path]
+		if !isFetch || !os.IsNotExist(err) {
+			return 0, false, fmt.Errorf("unable to stat payload file %q: %s", ck.Path, err)
+		}
+		if length < 0 {
+			return 0, false, nil
+		}
+		total += length
+	}
+	return total, true, nil
+}
@@ -0,0 +1,153 @@
+package bagit
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestParseBagInfo(t *testing.T) {
+	var raw = "Source-Organization: University of Oregon Libraries\r\n" +
+		"External-Identifier: batch_oru_foo\n" +
+		"External-Description: A long description which\n" +
+		"  wraps onto the next line\n" +
+		"\tand the one after that\n" +
+		"External-Identifier: batch_oru_foo_ver01\n"
+
+	var bi, err = ParseBagInfo(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Unable to parse bag info: %s", err)
+	}
+
+	assert.Equal(4, bi.Len(), "tag count", t)
+	assert.Equal("University of Oregon Libraries", bi.Get(SourceOrganization), "Source-Organization", t)
+	assert.Equal("University of Oregon Libraries", bi.Get("source-organization"), "case-insensitive lookup", t)
+	assert.Equal("A long description which\nwraps onto the next line\nand the one after that", bi.Get(ExternalDescription), "continuation lines", t)
+
+	var ids = bi.GetAll(ExternalIdentifier)
+	assert.Equal(2, len(ids), "External-Identifier count", t)
+	assert.Equal("batch_oru_foo", ids[0], "first External-Identifier", t)
+	assert.Equal("batch_oru_foo_ver01", ids[1], "second External-Identifier", t)
+
+	_, err = ParseBagInfo(strings.NewReader("  orphaned continuation\n"))
+	if err == nil {
+		t.Fatalf("A leading continuation line should be an error")
+	}
+	_, err = ParseBagInfo(strings.NewReader("No colon here\n"))
+	if err == nil {
+		t.Fatalf("A line with no label should be an error")
+	}
+}
+
+func TestBagInfoWrite(t *testing.T) {
+	var bi BagInfo
+	bi.Add(ExternalIdentifier, "one")
+	bi.Set(SourceOrganization, "UO")
+	bi.Add(ExternalIdentifier, "two")
+	bi.Set(ExternalDescription, "multiple\nlines")
+	bi.SetBaggingDate(time.Date(2020, 3, 4, 12, 0, 0, 0, time.UTC))
+	bi.Set("external-identifier", "three")
+
+	var buf bytes.Buffer
+	var err = bi.Write(&buf)
+	if err != nil {
+		t.Fatalf("Unable to write bag info: %s", err)
+	}
+
+	var expected = "external-identifier: three\n" +
+		"Source-Organization: UO\n" +
+		"External-Description: multiple\n" +
+		"  lines\n" +
+		"Bagging-Date: 2020-03-04\n"
+	assert.Equal(expected, buf.String(), "bag info output", t)
+
+	var bi2 *BagInfo
+	bi2, err = ParseBagInfo(&buf)
+	if err != nil {
+		t.Fatalf("Unable to re-parse bag info: %s", err)
+	}
+	assert.Equal(bi.Get(ExternalDescription), bi2.Get(ExternalDescription), "round-tripped description", t)
+
+	var d time.Time
+	d, err = bi2.BaggingDate()
+	assert.NilError(err, "parsing Bagging-Date", t)
+	assert.Equal("2020-03-04", d.Format("2006-01-02"), "Bagging-Date", t)
+
+	bi.Delete(ExternalIdentifier)
+	bi.Add("Bad: Label", "foo")
+	err = bi.Write(&buf)
+	if err == nil {
+		t.Fatalf("Writing a label containing a colon should be an error")
+	}
+}
+
+func TestWriteTagFilesBagInfo(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+	b.Info.Set(SourceOrganization, "UO")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var raw []byte
+	raw, err = ioutil.ReadFile(filepath.Join(root, "tagmanifest-sha256.txt"))
+	if err != nil {
+		t.Fatalf("Error reading tag manifest: %s", err)
+	}
+	if !strings.Contains(string(raw), "  bag-info.txt\n") {
+		t.Fatalf("Tag manifest should include bag-info.txt, but got %q", raw)
+	}
+
+	var b2 = New(root, hasher.NewSHA256())
+	var discrepancies []*Discrepancy
+	discrepancies, err = b2.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+
+	assert.Equal("UO", b2.Info.Get(SourceOrganization), "Source-Organization", t)
+	var octets, count int64
+	octets, count, err = b2.Info.PayloadOxum()
+	assert.NilError(err, "reading Payload-Oxum", t)
+	assert.Equal(int64(2), count, "Payload-Oxum file count", t)
+	assert.Equal(int64(23), octets, "Payload-Oxum octets", t)
+}
+
+func TestWriteTagFilesInvalidBagInfo(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+	b.Info.Set(SourceOrganization, "UO")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	// Rewrite the bag's tag files with an invalid label
+	var fname = filepath.Join(root, "bag-info.txt")
+	var before, _ = ioutil.ReadFile(fname)
+	os.Remove(filepath.Join(root, "manifest-sha256.txt"))
+	os.Remove(filepath.Join(root, "tagmanifest-sha256.txt"))
+	b.Info.Add("Bad: Label", "foo")
+	err = b.WriteTagFiles()
+	if err == nil {
+		t.Fatalf("Writing a label containing a colon should be an error")
+	}
+
+	var after []byte
+	after, err = ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("Existing bag-info.txt should survive a failed write: %s", err)
+	}
+	assert.Equal(string(before), string(after), "bag-info.txt after failed write", t)
+}
@@ -1,12 +1,17 @@
 package bagit
 
 import (
+	"bytes"
+	"context"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 
 	"github.com/uoregon-libraries/gopkg/fileutil"
 	"github.com/uoregon-libraries/gopkg/hasher"
@@ -18,58 +23,153 @@ type FileChecksum struct {
 	Checksum string
 }
 
-// Bag holds state for the generation of bag manifest and other tag files
+// Bag holds state for the generation of bag manifest and other tag files.
+//
+// All checksum data is keyed by the name of the hasher which generated it
+// (e.g., "sha256"), since a bag can have a manifest for each of its hashers.
 type Bag struct {
 	root              string
-	Hasher            *hasher.Hasher
+	Version           string // BagIt spec version; see Version097 and Version10
+	Hashers           []*hasher.Hasher
 	Cache             Cacher
-	ActualChecksums   []*FileChecksum // Checksums for everything in data/
-	ActualTagSums     []*FileChecksum // Checksums for all tag files
-	ManifestChecksums []*FileChecksum // Parsed checksum data from manifest-*.txt
-	ManifestTagSums   []*FileChecksum // Parsed checksum data from tagmanifest-*.txt
+	cacheLock         sync.Mutex
+	Info              BagInfo                    // Metadata for bag-info.txt
+	FetchItems        []*FetchItem               // Entries for fetch.txt
+	Workers           int                        // Number of files to hash concurrently
+	OnProgress        func(Progress)             // Called after each payload file is hashed
+	SpecialFiles      SpecialFilePolicy          // What to do with symlinks, FIFOs, etc. in the payload
+	Skipped           []*SkippedFile             // Special files left out of the last payload scan
+	ActualChecksums   map[string][]*FileChecksum // Checksums for everything in data/
+	ActualTagSums     map[string][]*FileChecksum // Checksums for all tag files
+	ManifestChecksums map[string][]*FileChecksum // Parsed checksum data from manifest-*.txt
+	ManifestTagSums   map[string][]*FileChecksum // Parsed checksum data from tagmanifest-*.txt
 }
 
-// New returns Bag structure for processing the given root path. h must be a
-// valid hasher.Hasher, e.g. hasher.MD5(), hasher.SHA256(), etc.
-func New(root string, h *hasher.Hasher) *Bag {
+// New returns Bag structure for processing the given root path. At least one
+// hasher must be given, and each must be a valid hasher.Hasher, e.g.
+// hasher.NewMD5(), hasher.NewSHA256(), etc. Each hasher gets its own manifest
+// and tag manifest, and all of them are checked when validating.
+//
+// The bag's Version defaults to Version097. Set it to Version10 prior to
+// writing tag files if you need an RFC 8493 bag. When reading or validating a
+// bag, Version is replaced by whatever the bag's "bagit.txt" declares.
+func New(root string, hashers ...*hasher.Hasher) *Bag {
 	return &Bag{
-		root:   root,
-		Hasher: h,
-		Cache:  noopCache{},
+		root:    root,
+		Version: Version097,
+		Hashers: hashers,
+		Cache:   noopCache{},
 	}
 }
 
-func readSums(fname string) ([]*FileChecksum, error) {
-	var data, err = ioutil.ReadFile(fname)
-	if err != nil {
-		return nil, err
+// Root returns the path to the bag's root directory
+func (b *Bag) Root() string {
+	return b.root
+}
+
+// validateHashers returns an error if the bag can't be used to generate or
+// read manifests due to a missing or duplicated hasher
+func (b *Bag) validateHashers() error {
+	if len(b.Hashers) == 0 {
+		return fmt.Errorf("bag has no hashers")
 	}
 
+	var seen = make(map[string]bool)
+	for _, h := range b.Hashers {
+		if h == nil {
+			return fmt.Errorf("bag has a nil hasher")
+		}
+		if seen[h.Name] {
+			return fmt.Errorf("bag has multiple %q hashers", h.Name)
+		}
+		seen[h.Name] = true
+	}
+
+	return nil
+}
+
+// parseSums reads manifest data for the given hasher, which determines the
+// length of a valid checksum. fname is only used for error reporting.
+// Windows line endings are accepted, and checksums are lowercased so they can
+// be compared directly with the ones we generate.
+func parseSums(data []byte, fname string, h *hasher.Hasher) ([]*FileChecksum, error) {
 	var sums []*FileChecksum
 	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+
 		// Blank lines are allowed, but skipped
-		if line == "" {
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		var parts = strings.Fields(line)
-		if len(parts) != 2 {
+		var sum, path, ok = parseManifestLine(line)
+		if !ok || !validChecksum(sum, h) {
 			return nil, fmt.Errorf("invalid manifest line in %q: %q", fname, line)
 		}
-		sums = append(sums, &FileChecksum{Checksum: parts[0], Path: parts[1]})
+		sums = append(sums, &FileChecksum{Checksum: strings.ToLower(sum), Path: decodePath(path)})
+	}
+
+	sortSums(sums)
+	return sums, nil
+}
+
+// parseManifestLine splits a manifest line into its checksum and path. The
+// path is everything after the first run of whitespace, so it may contain
+// spaces of its own. A "*" immediately after a single space is treated as
+// the binary-mode marker md5sum and friends use, and is not part of the path.
+func parseManifestLine(line string) (sum, path string, ok bool) {
+	line = strings.TrimLeft(line, " \t")
+	var i = strings.IndexAny(line, " \t")
+	if i < 0 {
+		return "", "", false
+	}
+
+	sum, path = line[:i], line[i:]
+	if strings.HasPrefix(path, " *") {
+		path = path[2:]
+	} else {
+		path = strings.TrimLeft(path, " \t")
 	}
+	return sum, path, path != ""
+}
 
+// validChecksum returns true if sum is a hex-encoded digest of the right
+// length for h
+func validChecksum(sum string, h *hasher.Hasher) bool {
+	if len(sum) != h.Size()*2 {
+		return false
+	}
+	var _, err = hex.DecodeString(sum)
+	return err == nil
+}
+
+// cutField splits s around the first run of spaces or tabs after any leading
+// whitespace, returning the first field and the remainder. ok is false if
+// there's no remainder.
+func cutField(s string) (field, rest string, ok bool) {
+	s = strings.TrimLeft(s, " \t")
+	var i = strings.IndexAny(s, " \t")
+	if i < 0 {
+		return s, "", false
+	}
+	rest = strings.TrimLeft(s[i:], " \t")
+	return s[:i], rest, rest != ""
+}
+
+func sortSums(sums []*FileChecksum) {
 	sort.Slice(sums, func(i, j int) bool {
 		return sums[i].Path < sums[j].Path
 	})
-
-	return sums, nil
 }
 
 // ReadManifests loads "manifest-[hashtype].txt" and, if present,
-// "tagmanifest-[hashtype].txt". Data is stored in the ManifestChecksums and
-// ManifestTagSums fields, respectively. It does *not* generate or validate
-// files in the bag.
+// "tagmanifest-[hashtype].txt" for each of the bag's hashers. Data is stored
+// in the ManifestChecksums and ManifestTagSums fields, respectively. It does
+// *not* generate or validate files in the bag.
+//
+// The bag declaration ("bagit.txt") is read first via ReadDeclaration, so a
+// bag with a missing or invalid declaration is rejected and b.Version matches
+// the bag on disk. Manifest paths are decoded the same way for every version.
 //
 // If an error occurs, it will be returned, and the bag's data may be in an
 // incomplete state and should not be relied upon.
@@ -77,68 +177,199 @@ func readSums(fname string) ([]*FileChecksum, error) {
 // Like the Generate... functions, ReadManifests will sort checksum data by
 // filepath, allowing for predictable manual comparisons if necessary.
 func (b *Bag) ReadManifests() error {
-	var err error
-	b.ManifestChecksums = nil
-	b.ManifestTagSums = nil
+	var unreadable, err = b.readManifests()
+	if err != nil {
+		return err
+	}
+	if len(unreadable) > 0 {
+		var d = unreadable[0]
+		return fmt.Errorf("unable to read manifest file %q: %w", filepath.Join(b.root, d.Path), d.Err)
+	}
 
-	// Manifest file must exist, so all errors are fatal
-	b.ManifestChecksums, err = readSums(b.manifestFilename())
+	return nil
+}
+
+// readManifests does the work for ReadManifests, but returns a discrepancy
+// for each manifest file which exists but can't be read or parsed. Errors are
+// reserved for problems which make the bag unusable, such as a missing
+// manifest or bag declaration.
+func (b *Bag) readManifests() (unreadable []*Discrepancy, err error) {
+	return b.readManifestsFrom(ioutil.ReadFile)
+}
+
+// readManifestsFrom is readManifests with a custom function for reading tag
+// files, so bags which aren't on disk can be read. readFile must return an
+// error satisfying os.IsNotExist for files which don't exist.
+func (b *Bag) readManifestsFrom(readFile func(string) ([]byte, error)) (unreadable []*Discrepancy, err error) {
+	err = b.validateHashers()
 	if err != nil {
-		return fmt.Errorf("unable to read manifest file %q: %w", b.manifestFilename(), err)
+		return nil, err
 	}
 
-	// Tag manifest is optional, so we handle the nonexistence separately from other errors
-	b.ManifestTagSums, err = readSums(b.tagManifestFilename())
-	if os.IsNotExist(err) {
+	b.ManifestChecksums = make(map[string][]*FileChecksum)
+	b.ManifestTagSums = make(map[string][]*FileChecksum)
+
+	err = b.readDeclarationFrom(readFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var readManifest = func(fname, label string, m map[string][]*FileChecksum, h *hasher.Hasher) error {
+		var data, err = readFile(fname)
+		var sums []*FileChecksum
+		if err == nil {
+			sums, err = parseSums(data, fname, h)
+		}
+		if err == nil {
+			m[h.Name] = sums
+			return nil
+		}
+		if os.IsNotExist(err) {
+			return err
+		}
+
+		unreadable = append(unreadable, &Discrepancy{
+			Kind:         UnreadableManifest,
+			Path:         filepath.Base(fname),
+			ManifestType: b.manifestLabel(label, h),
+			Err:          err,
+		})
 		return nil
 	}
-	if err != nil {
-		return fmt.Errorf("unable to read manifest file %q: %w", b.tagManifestFilename(), err)
+
+	for _, h := range b.Hashers {
+		// Manifest file must exist, so its nonexistence is fatal
+		var fname = b.manifestFilename(h)
+		err = readManifest(fname, "manifest", b.ManifestChecksums, h)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read manifest file %q: %w", fname, err)
+		}
+
+		// Tag manifest is optional, so we ignore the nonexistence error
+		readManifest(b.tagManifestFilename(h), "tag manifest", b.ManifestTagSums, h)
 	}
 
-	return nil
+	return unreadable, nil
 }
 
 // WriteTagFiles traverses all files under the bag's root/data, generates
-// hashes for each, and writes out "manifest-[hashtype].txt". Upon completion,
-// bagit.txt and tagmanifest-[hashtype].txt are then written.
+// hashes for each, and writes out "manifest-[hashtype].txt" for each of the
+// bag's hashers. Upon completion, bagit.txt and tagmanifest-[hashtype].txt
+// are then written. If b.Info has any tags, bag-info.txt is written prior to
+// the tag manifests so that they cover it. Payload-Oxum is computed if it
+// hasn't been set. Similarly, fetch.txt is written if b.FetchItems isn't
+// empty; see AddFetchItem for details on building a "holey" bag.
 //
-// This is not parallelized as it seems unlikely any advantage would be gained
-// since file IO is likely to be the main cost, not CPU.
-func (b *Bag) WriteTagFiles() (err error) {
-	err = b.GenerateChecksums()
+// Every file is read only once regardless of how many hashers the bag has.
+// By default files are hashed one at a time, since local disk IO is usually
+// the bottleneck, but networked storage often benefits from setting
+// b.Workers to hash several files at once.
+func (b *Bag) WriteTagFiles() error {
+	return b.WriteTagFilesContext(context.Background())
+}
+
+// WriteTagFilesContext is WriteTagFiles with support for cancellation. If ctx
+// is canceled while payload files are being hashed, no tag files are written,
+// and ctx's error is returned.
+func (b *Bag) WriteTagFilesContext(ctx context.Context) (err error) {
+	err = b.GenerateChecksumsContext(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err == nil {
+		err = b.writeTagFiles()
+	}
+
+	return
+}
+
+// writeTagFiles does the work of WriteTagFiles once b.ActualChecksums has
+// been populated
+func (b *Bag) writeTagFiles() (err error) {
+	err = b.addFetchSums()
 	if err == nil {
-		err = b.writeManifest()
+		err = b.writeManifests()
 	}
 	if err == nil {
 		err = b.writeBagitFile()
 	}
+	if err == nil {
+		err = b.writeFetchFile()
+	}
+	if err == nil {
+		err = b.writeBagInfo()
+	}
 	if err == nil {
 		err = b.GenerateTagSums()
 	}
 	if err == nil {
-		err = b.writeTagManifest()
+		err = b.writeTagManifests()
 	}
 
 	return
 }
 
 // GenerateChecksums iterates over all files in the data path and generates
-// each file's checksum in turn, storing the FileChecksums in
+// each file's checksums in turn, storing the FileChecksums in
 // b.ActualChecksums, sorted by file path. The checksum path is always relative
 // to the bag's root, which means it should always start with "data/".
 //
 // If there are any errors, relevant error information is returned. b.ActualChecksums
 // may be incomplete or incorrect in these cases, and should not be used.
 //
+// Files are hashed by b.Workers goroutines at once; see the Workers field.
+//
 // This is typically used internally to generate the manifest file, but can be
 // useful for testing, bag validation, or making use of the BagIt data
 // structure in cases where checksums need to be stored externally to the data.
 func (b *Bag) GenerateChecksums() error {
-	var err error
-	var realroot string
+	return b.GenerateChecksumsContext(context.Background())
+}
+
+// GenerateChecksumsContext is GenerateChecksums with support for cancellation
+// and progress reporting. Hashing stops as soon as ctx is canceled, even in
+// the middle of a file, and ctx's error is returned. If b.OnProgress is set,
+// it's called after each file is hashed.
+func (b *Bag) GenerateChecksumsContext(ctx context.Context) error {
+	return b.generateChecksums(ctx, true)
+}
+
+// generateChecksums does the work for GenerateChecksumsContext. The bag's
+// Cache is only used if useCache is true; validation needs to hash what's
+// actually on disk.
+func (b *Bag) generateChecksums(ctx context.Context, useCache bool) error {
+	var err = b.validateHashers()
+	if err != nil {
+		return err
+	}
 
-	realroot, err = filepath.Abs(b.root)
+	var files []payloadFile
+	err = b.walkPayload(func(path string, info os.FileInfo) error {
+		files = append(files, payloadFile{path: path, size: info.Size()})
+		return ctx.Err()
+	})
+	if err != nil {
+		return err
+	}
+
+	b.ActualChecksums = make(map[string][]*FileChecksum)
+	err = b.sumFiles(ctx, b.ActualChecksums, files, nil, useCache)
+
+	for _, sums := range b.ActualChecksums {
+		sortSums(sums)
+	}
+
+	return err
+}
+
+// walkPayload calls fn for every regular file under the bag's data path, in
+// lexical order. The bag's root is made absolute first, as all relative paths
+// we generate depend on it.
+//
+// Anything else is dealt with according to b.SpecialFiles, and b.Skipped is
+// reset to list whatever special files were skipped.
+func (b *Bag) walkPayload(fn func(path string, info os.FileInfo) error) error {
+	var realroot, err = filepath.Abs(b.root)
 	if err != nil {
 		return fmt.Errorf("unable to determine bag's absolute root path from %q: %s", b.root, err)
 	}
@@ -149,92 +380,151 @@ func (b *Bag) GenerateChecksums() error {
 		return fmt.Errorf(`%q is not a bag: missing or invalid "data" directory`, b.root)
 	}
 
-	b.ActualChecksums = nil
-	err = filepath.Walk(dataPath, func(path string, info os.FileInfo, err error) error {
-		// Don't try to proceed if there's already an error!
-		if err != nil {
-			return err
-		}
-
-		if info.Mode().IsRegular() {
-			var chksum, err = b.getsum(path)
-			if err == nil {
-				b.ActualChecksums = append(b.ActualChecksums, chksum)
-			}
-			return err
-		}
+	b.Skipped = nil
+	var w = &payloadWalker{bag: b, fn: fn, visiting: make(map[string]bool)}
+	return w.walkDir(dataPath)
+}
 
-		return nil
-	})
+// payloadSums returns the generated checksums for the bag's first hasher.
+// Every hasher's list covers the same files, so this is useful when the
+// caller only cares about which files are in the payload.
+func (b *Bag) payloadSums() []*FileChecksum {
+	return b.ActualChecksums[b.Hashers[0].Name]
+}
 
-	sort.Slice(b.ActualChecksums, func(i, j int) bool {
-		return b.ActualChecksums[i].Path < b.ActualChecksums[j].Path
-	})
+// addSums computes all checksums for the file at path, appending a
+// FileChecksum to each hasher's list in the given map
+func (b *Bag) addSums(m map[string][]*FileChecksum, path string, useCache bool) error {
+	var relPath, sums, err = b.getsums(context.Background(), path, b.Hashers, useCache)
+	if err != nil {
+		return err
+	}
 
-	return err
+	for _, h := range b.Hashers {
+		m[h.Name] = append(m[h.Name], &FileChecksum{Path: relPath, Checksum: sums[h.Name]})
+	}
+	return nil
 }
 
-func (b *Bag) getsum(path string) (*FileChecksum, error) {
+// getsums returns the bag-relative path and a map of hasher name to checksum
+// for the given file. If useCache is true, cached values are used where
+// possible and new values are stored. Anything not in the cache is computed
+// in a single read of the file using hashers, which must be equivalent to the
+// bag's hashers.
+func (b *Bag) getsums(ctx context.Context, path string, hashers []*hasher.Hasher, useCache bool) (string, map[string]string, error) {
 	var relPath, err = filepath.Rel(b.root, path)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
+		return "", nil, fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
 	}
 
-	var sum, exists = b.Cache.GetSum(relPath)
-	if !exists {
-		sum, err = b.compute(path)
+	// The cache needs the file's size and mtime from before it's hashed: if
+	// the file changes while we're reading it, its new size and mtime mustn't
+	// end up cached next to a checksum of the old data
+	var info os.FileInfo
+	if useCache {
+		info, err = os.Stat(path)
 		if err != nil {
-			return nil, err
+			return "", nil, fmt.Errorf("cannot compute checksum for %q: %s", path, err)
+		}
+	}
+
+	var sums = make(map[string]string)
+	var missing []*hasher.Hasher
+	for _, h := range hashers {
+		var sum, exists = "", false
+		if useCache {
+			sum, exists = b.getCachedSum(h.Name, relPath)
+		}
+		if exists {
+			sums[h.Name] = sum
+		} else {
+			missing = append(missing, h)
+		}
+	}
+
+	if len(missing) > 0 {
+		var computed, err = compute(ctx, path, missing)
+		if err != nil {
+			return "", nil, err
+		}
+		for name, sum := range computed {
+			sums[name] = sum
+		}
+	}
+
+	if useCache {
+		for _, h := range hashers {
+			b.setCachedSum(h.Name, relPath, sums[h.Name], info)
 		}
 	}
-	b.Cache.SetSum(path, sum)
 
-	return &FileChecksum{Path: relPath, Checksum: sum}, nil
+	return relPath, sums, nil
 }
 
-func (b *Bag) compute(path string) (string, error) {
-	var sum, err = b.Hasher.FileSum(path)
+// compute reads the file at path once, returning a map of hasher name to
+// checksum for each of the given hashers. Reading stops early if ctx is
+// canceled.
+func compute(ctx context.Context, path string, hashers []*hasher.Hasher) (map[string]string, error) {
+	var f, err = os.Open(path)
 	if err != nil {
-		return "", fmt.Errorf("cannot compute checksum for %q: %s", path, err)
+		return nil, fmt.Errorf("cannot compute checksum for %q: %s", path, err)
 	}
-	return sum, nil
+	defer f.Close()
+
+	var sums map[string]string
+	_, sums, err = copyAndHash(ioutil.Discard, &ctxReader{ctx: ctx, r: f}, hashers)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compute checksum for %q: %w", path, err)
+	}
+	return sums, nil
 }
 
-func (b *Bag) manifestFilename() string {
-	return filepath.Join(b.root, "manifest-"+b.Hasher.Name+".txt")
+func (b *Bag) manifestFilename(h *hasher.Hasher) string {
+	return filepath.Join(b.root, "manifest-"+h.Name+".txt")
 }
 
-func (b *Bag) tagManifestFilename() string {
-	return filepath.Join(b.root, "tagmanifest-"+b.Hasher.Name+".txt")
+func (b *Bag) tagManifestFilename(h *hasher.Hasher) string {
+	return filepath.Join(b.root, "tagmanifest-"+h.Name+".txt")
 }
 
-func (b *Bag) writeManifest() error {
-	var manifestFile = b.manifestFilename()
-	if !fileutil.MustNotExist(manifestFile) {
-		return fmt.Errorf("manifest file %q must not exist", manifestFile)
-	}
+func (b *Bag) writeManifests() error {
+	for _, h := range b.Hashers {
+		var manifestFile = b.manifestFilename(h)
+		if !fileutil.MustNotExist(manifestFile) {
+			return fmt.Errorf("manifest file %q must not exist", manifestFile)
+		}
 
-	var f = fileutil.NewSafeFile(manifestFile)
-	for _, ck := range b.ActualChecksums {
-		fmt.Fprintf(f, "%s  %s\n", ck.Checksum, ck.Path)
-	}
+		var f = fileutil.NewSafeFile(manifestFile)
+		var err = b.writeSums(f, b.ActualChecksums[h.Name])
+		if err != nil {
+			f.Cancel()
+			return fmt.Errorf("error writing manifest file: %s", err)
+		}
 
-	var err = f.Close()
-	if err != nil {
-		return fmt.Errorf("error writing manifest file: %s", err)
+		err = f.Close()
+		if err != nil {
+			return fmt.Errorf("error writing manifest file: %s", err)
+		}
 	}
 
 	return nil
 }
 
-func (b *Bag) writeBagitFile() error {
-	var f = fileutil.NewSafeFile(filepath.Join(b.root, "bagit.txt"))
-	f.Write([]byte("BagIt-Version: 0.97\nTag-File-Character-Encoding: UTF-8\n"))
-	return f.Close()
+// writeSums writes manifest lines for each checksum, percent-encoding paths
+// as the spec requires
+func (b *Bag) writeSums(w io.Writer, sums []*FileChecksum) error {
+	for _, ck := range sums {
+		var _, err = fmt.Fprintf(w, "%s  %s\n", ck.Checksum, encodePath(ck.Path))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 // GenerateTagSums iterates over all "tag" files (top-level files, not files in
-// data/) and generates each file's checksum in turn, storing them in
+// data/) and generates each file's checksums in turn, storing them in
 // b.ActualTagSums, sorted by file path. Files matching "tagmanifest-*.txt" are
 // skipped as tag manifests themselves are not "tag" files.
 //
@@ -245,126 +535,239 @@ func (b *Bag) writeBagitFile() error {
 // This is typically used internally to generate the tag manifest file, but can
 // be useful for testing or tag file validation.
 func (b *Bag) GenerateTagSums() error {
-	var infos, err = ioutil.ReadDir(b.root)
+	return b.generateTagSums(nil, nil, true)
+}
+
+// generateTagSums does the work for GenerateTagSums. Any file named in
+// pending is hashed using the given data rather than what's on disk, which
+// lets us compute tag manifests for tag files that haven't been written yet.
+//
+// If unreadable is non-nil, files which can't be read are recorded there and
+// left out of b.ActualTagSums rather than stopping with an error. The bag's
+// Cache is only used if useCache is true.
+func (b *Bag) generateTagSums(pending map[string][]byte, unreadable map[string]error, useCache bool) error {
+	var err = b.validateHashers()
+	if err != nil {
+		return err
+	}
+
+	var infos []os.FileInfo
+	infos, err = ioutil.ReadDir(b.root)
 	if err != nil {
 		return fmt.Errorf("error reading bag root: %s", err)
 	}
 
-	b.ActualTagSums = nil
+	var names []string
 	for _, info := range infos {
-		if !info.Mode().IsRegular() {
-			continue
+		var _, isPending = pending[info.Name()]
+		if info.Mode().IsRegular() && !isPending {
+			names = append(names, info.Name())
 		}
+	}
+	for name := range pending {
+		names = append(names, name)
+	}
 
-		var path = filepath.Join(b.root, info.Name())
+	b.ActualTagSums = make(map[string][]*FileChecksum)
+	for _, name := range names {
 		// Explicitly ignore the error here - if this pattern is broken, the caller
 		// has no way to fix it in any case. Better to just keep moving on.
-		var match, _ = filepath.Match("tagmanifest-*.txt", info.Name())
+		var match, _ = filepath.Match("tagmanifest-*.txt", name)
 		if match {
 			continue
 		}
 
-		var chksum, err = b.getsum(path)
-		if err != nil {
-			return fmt.Errorf("error getting %q's checksum: %s", path, err)
+		var path = filepath.Join(b.root, name)
+		var data, isPending = pending[name]
+		if !isPending {
+			err = b.addSums(b.ActualTagSums, path, useCache)
+			if err != nil && unreadable != nil {
+				unreadable[name] = err
+				err = nil
+			}
+			if err != nil {
+				return fmt.Errorf("error getting %q's checksum: %s", path, err)
+			}
+			continue
+		}
+
+		var _, sums, _ = copyAndHash(ioutil.Discard, bytes.NewReader(data), b.Hashers)
+		for _, h := range b.Hashers {
+			b.ActualTagSums[h.Name] = append(b.ActualTagSums[h.Name], &FileChecksum{Path: name, Checksum: sums[h.Name]})
 		}
-		b.ActualTagSums = append(b.ActualTagSums, chksum)
 	}
 
-	sort.Slice(b.ActualTagSums, func(i, j int) bool {
-		return b.ActualTagSums[i].Path < b.ActualTagSums[j].Path
-	})
+	for _, sums := range b.ActualTagSums {
+		sortSums(sums)
+	}
 
 	return nil
 }
 
-func (b *Bag) writeTagManifest() error {
-	var manifestFile = b.tagManifestFilename()
-	if !fileutil.MustNotExist(manifestFile) {
-		return fmt.Errorf("tag manifest file %q must not exist", manifestFile)
-	}
+func (b *Bag) writeTagManifests() error {
+	for _, h := range b.Hashers {
+		var manifestFile = b.tagManifestFilename(h)
+		if !fileutil.MustNotExist(manifestFile) {
+			return fmt.Errorf("tag manifest file %q must not exist", manifestFile)
+		}
 
-	var f = fileutil.NewSafeFile(manifestFile)
-	for _, ck := range b.ActualTagSums {
-		fmt.Fprintf(f, "%s  %s\n", ck.Checksum, ck.Path)
-	}
+		var f = fileutil.NewSafeFile(manifestFile)
+		var err = b.writeSums(f, b.ActualTagSums[h.Name])
+		if err != nil {
+			f.Cancel()
+			return fmt.Errorf("error writing tag manifest file: %s", err)
+		}
 
-	var err = f.Close()
-	if err != nil {
-		return fmt.Errorf("error writing tag manifest file: %s", err)
+		err = f.Close()
+		if err != nil {
+			return fmt.Errorf("error writing tag manifest file: %s", err)
+		}
 	}
 
 	return nil
 }
 
-// Validate reads all manifest files (standard manifest plus the optional tag
-// manifest), generates fresh checksums, and compares what the manifest claims
-// we should have to what's actually on disk. The return will contain any
-// discrepancies in a human-readable format.
+// manifestLabel returns the text used to describe a manifest when reporting
+// discrepancies. Single-hasher bags just get "manifest" or "tag manifest", but
+// with multiple hashers we have to say which manifest we mean.
+func (b *Bag) manifestLabel(kind string, h *hasher.Hasher) string {
+	if len(b.Hashers) == 1 {
+		return kind
+	}
+	return h.Name + " " + kind
+}
+
+// Validate reads all manifest files (standard manifests plus the optional tag
+// manifests) for each of the bag's hashers, generates fresh checksums, and
+// compares what the manifests claim we should have to what's actually on
+// disk. The return will contain any discrepancies found.
 //
 // If something fails, as opposed to there being incorrect data or manifests,
 // an error will be returned and discrepancies will be empty. This can happen
 // if there are no manifest files, if there is no "data" directory, if files
-// are unreadable, etc.
+// are unreadable, if "bagit.txt" is missing or declares a version or encoding
+// we don't support, etc. A manifest which exists but can't be read or parsed
+// is reported as an UnreadableManifest discrepancy, and no further validation
+// is done.
 //
-// If a tag manifest is present, it is validated first, and the rest of the bag
-// *is not validated* if the tag manifest has discrepancies. This avoids
+// If tag manifests are present, they are validated first, and the rest of the
+// bag *is not validated* if any tag manifest has discrepancies. This avoids
 // unnecessary work when there are easily-identified top-level bag problems.
-func (b *Bag) Validate() (discrepancies []string, err error) {
-	err = b.ReadManifests()
+func (b *Bag) Validate() ([]*Discrepancy, error) {
+	return b.ValidateContext(context.Background())
+}
+
+// ValidateContext is Validate with support for cancellation and progress
+// reporting; see GenerateChecksumsContext.
+func (b *Bag) ValidateContext(ctx context.Context) (discrepancies []*Discrepancy, err error) {
+	discrepancies, err = b.readManifests()
+	if err == nil {
+		err = b.ReadBagInfo()
+	}
 	if err != nil {
 		return nil, err
 	}
+	if len(discrepancies) > 0 {
+		return discrepancies, nil
+	}
 
-	if len(b.ManifestChecksums) == 0 {
-		return nil, fmt.Errorf("%s contains no data", b.manifestFilename())
+	err = b.checkManifestData()
+	if err != nil {
+		return nil, err
 	}
 
 	if len(b.ManifestTagSums) > 0 {
-		err = b.GenerateTagSums()
+		err = b.generateTagSums(nil, nil, false)
 		if err != nil {
 			return nil, err
 		}
 
-		discrepancies = Compare("tag manifest", b.ManifestTagSums, b.ActualTagSums)
+		discrepancies = b.compareTagSums()
 		if len(discrepancies) > 0 {
 			return discrepancies, nil
 		}
 	}
 
-	err = b.GenerateChecksums()
+	err = b.generateChecksums(ctx, false)
 	if err != nil {
 		return nil, err
 	}
-	discrepancies = Compare("manifest", b.ManifestChecksums, b.ActualChecksums)
 
-	return discrepancies, nil
+	return b.compareChecksums(), nil
+}
+
+// checkManifestData returns an error if any payload manifest is empty.
+// Manifests which couldn't be read have no entry in b.ManifestChecksums, and
+// are skipped, as they've already been reported as discrepancies, but at
+// least one manifest has to have been readable.
+func (b *Bag) checkManifestData() error {
+	var readable int
+	for _, h := range b.Hashers {
+		var sums, ok = b.ManifestChecksums[h.Name]
+		if !ok {
+			continue
+		}
+		if len(sums) == 0 {
+			return fmt.Errorf("%s contains no data", b.manifestFilename(h))
+		}
+		readable++
+	}
+	if readable == 0 {
+		return fmt.Errorf("no payload manifest could be read")
+	}
+	return nil
+}
+
+// compareTagSums compares b.ActualTagSums to each tag manifest in
+// b.ManifestTagSums
+func (b *Bag) compareTagSums() []*Discrepancy {
+	var discrepancies []*Discrepancy
+	for _, h := range b.Hashers {
+		var sums, ok = b.ManifestTagSums[h.Name]
+		if ok {
+			var label = b.manifestLabel("tag manifest", h)
+			discrepancies = append(discrepancies, Compare(label, sums, b.ActualTagSums[h.Name])...)
+		}
+	}
+	return discrepancies
+}
+
+// compareChecksums compares b.ActualChecksums to each payload manifest in
+// b.ManifestChecksums
+func (b *Bag) compareChecksums() []*Discrepancy {
+	var discrepancies []*Discrepancy
+	for _, h := range b.Hashers {
+		var label = b.manifestLabel("manifest", h)
+		discrepancies = append(discrepancies, Compare(label, b.ManifestChecksums[h.Name], b.ActualChecksums[h.Name])...)
+	}
+	return discrepancies
 }
 
 // Compare validates a manifest file's checksums against the actual checksums
-// from the filesystem. The return will contain any discrepancies in a
-// human-readable format.
+// from the filesystem. The return will contain any discrepancies found,
+// sorted by path.
 //
 // This is normally not meant to be used externally, but it can be handy to
 // validate two separate bags or do a simple compare just of tag manifests or
 // something.
 //
-// manifestType should describe the kind of manifest - internally we only use
-// "tag manifest" and "manifest". This string is used when reporting
-// discrepancies, e.g.: "tag manifest lists the file, but blah blah blah".
-func Compare(manifestType string, manifest, actual []*FileChecksum) []string {
+// manifestType should describe the kind of manifest - internally we use
+// "tag manifest" and "manifest", prefixed with the hash algorithm when a bag
+// has more than one. This string is used when reporting discrepancies, e.g.:
+// "tag manifest lists the file, but blah blah blah".
+func Compare(manifestType string, manifest, actual []*FileChecksum) []*Discrepancy {
 	var manifestMap = mapify(manifest)
 	var actualMap = mapify(actual)
-	var errs []string
+	var errs []*Discrepancy
 
 	// Step 1: everything in the manifest should have a corresponding (and equal)
 	// item in the generated list
 	for path := range manifestMap {
 		var mchk, achk = manifestMap[path], actualMap[path]
 		if achk == "" {
-			errs = append(errs, fmt.Sprintf("missing file: %q (%s lists the file, but it is not present on disk)", path, manifestType))
+			errs = append(errs, missingFile(manifestType, path, mchk))
 		} else if achk != mchk {
-			errs = append(errs, fmt.Sprintf("corrupt file: %q (%s checksum was %q, actual checksum was %q", path, manifestType, mchk, achk))
+			errs = append(errs, &Discrepancy{Kind: Corrupt, Path: path, ManifestType: manifestType, Expected: mchk, Actual: achk})
 		}
 	}
 
@@ -372,10 +775,11 @@ func Compare(manifestType string, manifest, actual []*FileChecksum) []string {
 	// at all; we do not re-validate items that are in both lists here
 	for path := range actualMap {
 		if manifestMap[path] == "" {
-			errs = append(errs, fmt.Sprintf("extra file: %q (%s does not list the file, but it is present on disk)", path, manifestType))
+			errs = append(errs, extraFile(manifestType, path, actualMap[path]))
 		}
 	}
 
+	sortDiscrepancies(errs)
 	return errs
 }
 
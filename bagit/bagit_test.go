@@ -2,6 +2,8 @@ package bagit
 
 import (
 	"fmt"
+	"hash"
+	"hash/crc32"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -28,9 +30,9 @@ func TestGenerateChecksums(t *testing.T) {
 		"55f8718109829bf506b09d8af615b9f107a266e19f7a311039d1035f180b22d4", // test.txt's "sha256sum" value
 	}
 
-	assert.Equal(len(expectedChecksums), len(b.ActualChecksums), "checksum list length", t)
+	assert.Equal(len(expectedChecksums), len(b.ActualChecksums["sha256"]), "checksum list length", t)
 
-	for i, ck := range b.ActualChecksums {
+	for i, ck := range b.ActualChecksums["sha256"] {
 		assert.Equal(expectedChecksums[i], ck.Checksum, "checksum for "+ck.Path, t)
 	}
 }
@@ -97,7 +99,7 @@ func TestValidate(t *testing.T) {
 	}
 
 	var b2 = New(path, hasher.NewSHA256())
-	var discrepancies []string
+	var discrepancies []*Discrepancy
 	discrepancies, err = b2.Validate()
 	if err != nil {
 		t.Fatalf("Unable to validate: %s", err)
@@ -106,7 +108,7 @@ func TestValidate(t *testing.T) {
 		t.Fatalf("TagSums should not be empty")
 	}
 	if len(discrepancies) > 0 {
-		t.Fatalf("Validation failed: %s", strings.Join(discrepancies, ", "))
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
 	}
 
 	// It should be fine without a tag manifest; it just won't have that data
@@ -120,7 +122,7 @@ func TestValidate(t *testing.T) {
 		t.Fatalf("TagSums should be empty")
 	}
 	if len(discrepancies) > 0 {
-		t.Fatalf("Validation failed: %s", strings.Join(discrepancies, ", "))
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
 	}
 
 	// This should puke - manifest is required
@@ -161,9 +163,357 @@ func TestGenerateChecksumsWithCache(t *testing.T) {
 		"55f8718109829bf506b09d8af615b9f107a266e19f7a311039d1035f180b22d4", // test.txt's actual value
 	}
 
-	assert.Equal(len(expectedChecksums), len(b.ActualChecksums), "checksum list length", t)
+	assert.Equal(len(expectedChecksums), len(b.ActualChecksums["sha256"]), "checksum list length", t)
 
-	for i, ck := range b.ActualChecksums {
+	for i, ck := range b.ActualChecksums["sha256"] {
 		assert.Equal(expectedChecksums[i], ck.Checksum, "checksum for "+ck.Path, t)
 	}
 }
+
+// makeTestBag copies the testdata payload into a new temporary bag and
+// returns the bag's root path
+func makeTestBag(t *testing.T) string {
+	var root = t.TempDir()
+	var dataPath = filepath.Join(root, "data")
+	var err = os.Mkdir(dataPath, 0755)
+	if err != nil {
+		t.Fatalf("Unable to create %q: %s", dataPath, err)
+	}
+
+	for _, name := range []string{"another.txt", "test.txt"} {
+		var raw, err = ioutil.ReadFile(filepath.Join("testdata", "data", name))
+		if err != nil {
+			t.Fatalf("Unable to read test file %q: %s", name, err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dataPath, name), raw, 0644)
+		if err != nil {
+			t.Fatalf("Unable to write test file %q: %s", name, err)
+		}
+	}
+
+	return root
+}
+
+func TestWriteTagFilesVersion10(t *testing.T) {
+	var root = makeTestBag(t)
+	var err = ioutil.WriteFile(filepath.Join(root, "data", "100%\nodd.txt"), []byte("odd"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write test file: %s", err)
+	}
+
+	var b = New(root, hasher.NewSHA256())
+	b.Version = Version10
+	err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var raw []byte
+	raw, err = ioutil.ReadFile(filepath.Join(root, "bagit.txt"))
+	if err != nil {
+		t.Fatalf("Error reading bagit.txt: %s", err)
+	}
+	assert.Equal("BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n", string(raw), "bagit.txt", t)
+
+	raw, err = ioutil.ReadFile(filepath.Join(root, "manifest-sha256.txt"))
+	if err != nil {
+		t.Fatalf("Error reading manifest: %s", err)
+	}
+	if !strings.Contains(string(raw), "  data/100%25%0Aodd.txt\n") {
+		t.Fatalf("Manifest should have percent-encoded the odd filename, but got %q", raw)
+	}
+
+	var b2 = New(root, hasher.NewSHA256())
+	var discrepancies []*Discrepancy
+	discrepancies, err = b2.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+	assert.Equal(Version10, b2.Version, "validated bag's version", t)
+}
+
+func TestSpecialFilenames(t *testing.T) {
+	var root = makeTestBag(t)
+	for _, name := range []string{"line\nbreak.txt", "has  spaces.txt", " leading space.txt", "*star.txt"} {
+		var err = ioutil.WriteFile(filepath.Join(root, "data", name), []byte("odd"), 0644)
+		if err != nil {
+			t.Fatalf("Unable to write test file: %s", err)
+		}
+	}
+
+	var b = New(root, hasher.NewSHA256())
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var raw []byte
+	raw, err = ioutil.ReadFile(filepath.Join(root, "manifest-sha256.txt"))
+	if err != nil {
+		t.Fatalf("Error reading manifest: %s", err)
+	}
+	if !strings.Contains(string(raw), "  data/line%0Abreak.txt\n") {
+		t.Fatalf("Manifest should have percent-encoded the newline, but got %q", raw)
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewSHA256()).Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+}
+
+func TestParseSums(t *testing.T) {
+	var data = "7d980e6be82dd33893f39d652d8a99a1  data/has  spaces.txt\r\n" +
+		"b05403212c66bdc8ccc597fedf6cd5fe *data/binary.txt\r\n" +
+		"\r\n" +
+		"D41D8CD98F00B204E9800998ECF8427E\tdata/tab.txt\n" +
+		"00000000000000000000000000000000  data/100%25%0D%0Aodd.txt\n"
+	var sums, err = parseSums([]byte(data), "manifest-md5.txt", hasher.NewMD5())
+	if err != nil {
+		t.Fatalf("Unable to parse manifest: %s", err)
+	}
+
+	var expected = []FileChecksum{
+		{Path: "data/100%\r\nodd.txt", Checksum: "00000000000000000000000000000000"},
+		{Path: "data/binary.txt", Checksum: "b05403212c66bdc8ccc597fedf6cd5fe"},
+		{Path: "data/has  spaces.txt", Checksum: "7d980e6be82dd33893f39d652d8a99a1"},
+		{Path: "data/tab.txt", Checksum: "d41d8cd98f00b204e9800998ecf8427e"},
+	}
+	assert.Equal(len(expected), len(sums), "checksum count", t)
+	for i := range expected {
+		assert.Equal(expected[i], *sums[i], "checksum "+expected[i].Path, t)
+	}
+
+	var invalid = map[string]string{
+		"no path":         "7d980e6be82dd33893f39d652d8a99a1\n",
+		"not hex":         "this is not a manifest line\n",
+		"too short":       "7d980e6b  data/test.txt\n",
+		"wrong algorithm": "60fa80b948a0acc557a6ba7523f4040a7b452736723df20f118d0aacb5c1901b  data/test.txt\n",
+	}
+	for name, line := range invalid {
+		_, err = parseSums([]byte(line), "manifest-md5.txt", hasher.NewMD5())
+		if err == nil {
+			t.Errorf("%s: %q should be an error", name, line)
+		}
+	}
+}
+
+func TestValidateUppercaseManifest(t *testing.T) {
+	var root = makeTestBag(t)
+	var err = New(root, hasher.NewMD5()).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+	os.Remove(filepath.Join(root, "tagmanifest-md5.txt"))
+
+	var fname = filepath.Join(root, "manifest-md5.txt")
+	var data []byte
+	data, err = ioutil.ReadFile(fname)
+	if err != nil {
+		t.Fatalf("Unable to read manifest: %s", err)
+	}
+	var lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	for i, line := range lines {
+		var sum, path, _ = parseManifestLine(line)
+		lines[i] = strings.ToUpper(sum) + "  " + path
+	}
+	err = ioutil.WriteFile(fname, []byte(strings.Join(lines, "\n")+"\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write manifest: %s", err)
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewMD5()).Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Bag with uppercase checksums is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+}
+
+func TestValidateDeclaration(t *testing.T) {
+	var tests = map[string]string{
+		"unknown version":  "BagIt-Version: 0.96\nTag-File-Character-Encoding: UTF-8\n",
+		"unknown encoding": "BagIt-Version: 1.0\nTag-File-Character-Encoding: ISO-8859-1\n",
+		"missing version":  "Tag-File-Character-Encoding: UTF-8\n",
+		"missing encoding": "BagIt-Version: 1.0\n",
+		"bad label":        "BagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\nFoo: bar\n",
+		"1.0 with BOM":     "\xEF\xBB\xBFBagIt-Version: 1.0\nTag-File-Character-Encoding: UTF-8\n",
+	}
+
+	var root = makeTestBag(t)
+	var err = New(root, hasher.NewSHA256()).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+	os.Remove(filepath.Join(root, "tagmanifest-sha256.txt"))
+
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			var err = ioutil.WriteFile(filepath.Join(root, "bagit.txt"), []byte(contents), 0644)
+			if err != nil {
+				t.Fatalf("Unable to write bagit.txt: %s", err)
+			}
+			_, err = New(root, hasher.NewSHA256()).Validate()
+			if err == nil {
+				t.Fatalf("Expected an error validating a bag declaration of %q", contents)
+			}
+		})
+	}
+
+	os.Remove(filepath.Join(root, "bagit.txt"))
+	var _, verr = New(root, hasher.NewSHA256()).Validate()
+	if verr == nil {
+		t.Fatalf("Lack of a bagit.txt should get an error, but we didn't get one")
+	}
+}
+
+func TestMultipleHashers(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewMD5(), hasher.NewSHA256())
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var expected = map[string]string{
+		"manifest-md5.txt":    "7d980e6be82dd33893f39d652d8a99a1  data/another.txt\nb05403212c66bdc8ccc597fedf6cd5fe  data/test.txt\n",
+		"manifest-sha256.txt": "60fa80b948a0acc557a6ba7523f4040a7b452736723df20f118d0aacb5c1901b  data/another.txt\n55f8718109829bf506b09d8af615b9f107a266e19f7a311039d1035f180b22d4  data/test.txt\n",
+	}
+	for fname, contents := range expected {
+		var raw, err = ioutil.ReadFile(filepath.Join(root, fname))
+		if err != nil {
+			t.Fatalf("error reading %q: %s", fname, err)
+		}
+		assert.Equal(contents, string(raw), fname, t)
+	}
+
+	// Each tag manifest should list every manifest
+	for _, fname := range []string{"tagmanifest-md5.txt", "tagmanifest-sha256.txt"} {
+		var raw, err = ioutil.ReadFile(filepath.Join(root, fname))
+		if err != nil {
+			t.Fatalf("error reading %q: %s", fname, err)
+		}
+		for _, tagfile := range []string{"bagit.txt", "manifest-md5.txt", "manifest-sha256.txt"} {
+			if !strings.Contains(string(raw), "  "+tagfile+"\n") {
+				t.Errorf("%s should list %s, but got %q", fname, tagfile, raw)
+			}
+		}
+	}
+
+	var b2 = New(root, hasher.NewMD5(), hasher.NewSHA256())
+	var discrepancies []*Discrepancy
+	discrepancies, err = b2.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+
+	// Corrupt just the md5 manifest; validation must catch it
+	var fname = filepath.Join(root, "manifest-md5.txt")
+	err = ioutil.WriteFile(fname, []byte("7d980e6be82dd33893f39d652d8a99a1  data/another.txt\n00000000000000000000000000000000  data/test.txt\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to rewrite %q: %s", fname, err)
+	}
+	os.Remove(filepath.Join(root, "tagmanifest-md5.txt"))
+	os.Remove(filepath.Join(root, "tagmanifest-sha256.txt"))
+	discrepancies, err = b2.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "discrepancy count", t)
+	assert.Equal(Corrupt, discrepancies[0].Kind, "discrepancy kind", t)
+	assert.Equal("md5 manifest", discrepancies[0].ManifestType, "discrepancy manifest type", t)
+	if !strings.Contains(discrepancies[0].String(), "md5 manifest checksum") {
+		t.Fatalf("Expected an md5 manifest discrepancy, got %q", discrepancies[0])
+	}
+}
+
+func TestOpen(t *testing.T) {
+	var root = makeTestBag(t)
+	var _, err = Open(root)
+	if err == nil {
+		t.Fatalf("Opening a directory with no manifests should be an error")
+	}
+
+	err = New(root, hasher.NewSHA512(), hasher.NewMD5()).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var b *Bag
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	assert.Equal(2, len(b.Hashers), "hasher count", t)
+	assert.Equal("md5", b.Hashers[0].Name, "first hasher", t)
+	assert.Equal("sha512", b.Hashers[1].Name, "second hasher", t)
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+
+	var fname = filepath.Join(root, "tagmanifest-sha1.txt")
+	err = ioutil.WriteFile(fname, nil, 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+	_, err = Open(root)
+	if err == nil {
+		t.Fatalf("A tag manifest without a matching manifest should be an error")
+	}
+	os.Remove(fname)
+
+	fname = filepath.Join(root, "manifest-bogus.txt")
+	err = ioutil.WriteFile(fname, nil, 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+	_, err = Open(root)
+	if err == nil {
+		t.Fatalf("A manifest with an unknown algorithm should be an error")
+	}
+}
+
+func TestOpenRegisteredAlgos(t *testing.T) {
+	var root = makeTestBag(t)
+	hasher.Register("test-crc32", func() hash.Hash { return crc32.NewIEEE() })
+	var err = New(root, hasher.New(hasher.SHA3_256), hasher.FromString("test-crc32")).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var b *Bag
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	assert.Equal(2, len(b.Hashers), "hasher count", t)
+	assert.Equal("sha3-256", b.Hashers[0].Name, "first hasher", t)
+	assert.Equal("test-crc32", b.Hashers[1].Name, "second hasher", t)
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+}
@@ -1,13 +1,36 @@
 package bagit
 
+import "os"
+
 // The Cacher interface defines a simple way for a bag's manifest data to
 // optionally be cached or pre-computed by the caller when building manifests.
-// Validation functions do not use this.
+// Validation functions do not use this: they always hash the files on disk.
+// Paths are always relative to the bag's root, e.g., "data/foo.txt".
+//
+// A Cacher only stores a single checksum per file, so a bag with multiple
+// hashers will ignore its cache unless it also implements AlgoCacher.
+//
+// FileCache is a persistent AlgoCacher suitable for most uses.
 type Cacher interface {
 	GetSum(path string) (value string, exists bool)
 	SetSum(path, value string)
 }
 
+// AlgoCacher is a Cacher which can store a checksum per hash algorithm. If a
+// bag's Cache implements this, it's used instead of the plain Cacher methods.
+type AlgoCacher interface {
+	Cacher
+	GetAlgoSum(algo, path string) (value string, exists bool)
+	SetAlgoSum(algo, path, value string)
+}
+
+// infoCacher is implemented by caches which tie checksums to a file's size
+// and mtime. setCachedSum uses it to pass in the file's info from before it
+// was hashed, so changes made during hashing invalidate the entry.
+type infoCacher interface {
+	setAlgoSumInfo(algo, path, value string, info os.FileInfo)
+}
+
 type noopCache struct{}
 
 func (noopCache) GetSum(_ string) (string, bool) {
@@ -16,3 +39,38 @@ func (noopCache) GetSum(_ string) (string, bool) {
 
 func (noopCache) SetSum(_, _ string) {
 }
+
+// getCachedSum and setCachedSum lock the cache so it's safe to call them
+// from multiple workers, since we don't expect Cacher implementations to be
+// safe for concurrent use
+func (b *Bag) getCachedSum(algo, path string) (string, bool) {
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	if c, ok := b.Cache.(AlgoCacher); ok {
+		return c.GetAlgoSum(algo, path)
+	}
+	if len(b.Hashers) == 1 {
+		return b.Cache.GetSum(path)
+	}
+	return "", false
+}
+
+// setCachedSum stores a checksum in the bag's cache. info should be the
+// file's info from before it was hashed, or nil if that isn't known.
+func (b *Bag) setCachedSum(algo, path, value string, info os.FileInfo) {
+	b.cacheLock.Lock()
+	defer b.cacheLock.Unlock()
+
+	if c, ok := b.Cache.(infoCacher); ok && info != nil {
+		c.setAlgoSumInfo(algo, path, value, info)
+		return
+	}
+	if c, ok := b.Cache.(AlgoCacher); ok {
+		c.SetAlgoSum(algo, path, value)
+		return
+	}
+	if len(b.Hashers) == 1 {
+		b.Cache.SetSum(path, value)
+	}
+}
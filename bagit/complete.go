@@ -0,0 +1,117 @@
+package bagit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+)
+
+// ValidateComplete is a more thorough Validate meant for diagnosing a broken
+// bag: rather than stopping at the first sign of trouble, it checks
+// everything it can and returns a single report. Specifically:
+//
+//   - Unreadable manifests are reported, and any other manifests are still
+//     checked
+//   - An unparseable bag-info.txt is reported as an Unreadable discrepancy
+//   - The payload is checked even if the tag manifests have discrepancies
+//   - If bag-info.txt has a Payload-Oxum, it's checked as in ValidateQuick
+//   - Tag and payload files which can't be read are reported as Unreadable
+//     discrepancies instead of causing an error
+//
+// Errors are still returned for problems which prevent validation entirely,
+// such as a missing or empty payload manifest, a bag with no readable payload
+// manifest at all, a missing bag declaration, or a payload directory which
+// can't be read.
+func (b *Bag) ValidateComplete() ([]*Discrepancy, error) {
+	return b.ValidateCompleteContext(context.Background())
+}
+
+// ValidateCompleteContext is ValidateComplete with support for cancellation
+// and progress reporting; see GenerateChecksumsContext.
+func (b *Bag) ValidateCompleteContext(ctx context.Context) (discrepancies []*Discrepancy, err error) {
+	discrepancies, err = b.readManifests()
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.checkManifestData()
+	if err != nil {
+		return nil, err
+	}
+
+	var infoErr = b.ReadBagInfo()
+	if infoErr != nil {
+		var fname = filepath.Base(b.bagInfoFilename())
+		discrepancies = append(discrepancies, &Discrepancy{Kind: Unreadable, Path: fname, Err: infoErr})
+	}
+
+	if len(b.ManifestTagSums) > 0 {
+		var unreadable = make(map[string]error)
+		err = b.generateTagSums(nil, unreadable, false)
+		if err != nil {
+			return nil, err
+		}
+		discrepancies = append(discrepancies, unreadableDiscrepancies(unreadable, b.compareTagSums())...)
+	}
+
+	var files []payloadFile
+	var octets int64
+	err = b.walkPayload(func(path string, info os.FileInfo) error {
+		files = append(files, payloadFile{path: path, size: info.Size()})
+		octets += info.Size()
+		return ctx.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var unreadable = make(map[string]error)
+	b.ActualChecksums = make(map[string][]*FileChecksum)
+	err = b.sumFiles(ctx, b.ActualChecksums, files, unreadable, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, sums := range b.ActualChecksums {
+		sortSums(sums)
+	}
+
+	// Payload manifests which couldn't be read have no entry in
+	// b.ManifestChecksums, and have already been reported
+	var compared []*Discrepancy
+	for _, h := range b.Hashers {
+		var sums, ok = b.ManifestChecksums[h.Name]
+		if ok {
+			var label = b.manifestLabel("manifest", h)
+			compared = append(compared, Compare(label, sums, b.ActualChecksums[h.Name])...)
+		}
+	}
+	discrepancies = append(discrepancies, unreadableDiscrepancies(unreadable, compared)...)
+
+	if infoErr == nil {
+		var d = b.checkOxum(octets, int64(len(files)))
+		if d != nil {
+			discrepancies = append(discrepancies, d)
+		}
+	}
+
+	sortDiscrepancies(discrepancies)
+	return discrepancies, nil
+}
+
+// unreadableDiscrepancies returns an Unreadable discrepancy for each file in
+// unreadable, along with all discrepancies from list except "missing file"
+// reports for those files: they're missing from the generated checksums
+// because they couldn't be read, not because they aren't there.
+func unreadableDiscrepancies(unreadable map[string]error, list []*Discrepancy) []*Discrepancy {
+	var result []*Discrepancy
+	for path, err := range unreadable {
+		result = append(result, &Discrepancy{Kind: Unreadable, Path: path, Err: err})
+	}
+	for _, d := range list {
+		if d.Kind == Missing && unreadable[d.Path] != nil {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
@@ -0,0 +1,157 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestValidateComplete(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+	b.Info.Set(SourceOrganization, "UO")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateComplete()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+
+	// Break a tag file and a payload file: Validate stops at the tag manifest,
+	// but a complete validation should report both
+	var infoFile = filepath.Join(root, "bag-info.txt")
+	var info, _ = ioutil.ReadFile(infoFile)
+	err = ioutil.WriteFile(infoFile, append(info, []byte("Contact-Name: Somebody\n")...), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", infoFile, err)
+	}
+	var payloadFile = filepath.Join(root, "data", "test.txt")
+	err = ioutil.WriteFile(payloadFile, []byte("corrupted!"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", payloadFile, err)
+	}
+
+	discrepancies, err = New(root, hasher.NewSHA256()).Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "Validate discrepancies", t)
+
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateComplete()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) != 2 {
+		t.Fatalf("Expected 2 discrepancies, got: %s", joinDiscrepancies(discrepancies))
+	}
+	assert.Equal("bag-info.txt", discrepancies[0].Path, "tag file discrepancy path", t)
+	assert.Equal(Corrupt, discrepancies[0].Kind, "tag file discrepancy kind", t)
+	assert.Equal("data/test.txt", discrepancies[1].Path, "payload discrepancy path", t)
+	assert.Equal(Corrupt, discrepancies[1].Kind, "payload discrepancy kind", t)
+
+	// A truncated file also shows up in the oxum
+	err = ioutil.WriteFile(payloadFile, []byte("short"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", payloadFile, err)
+	}
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateComplete()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) != 3 {
+		t.Fatalf("Expected 3 discrepancies, got: %s", joinDiscrepancies(discrepancies))
+	}
+	assert.Equal(OxumMismatch, discrepancies[1].Kind, "oxum discrepancy kind", t)
+}
+
+func TestValidateCompleteUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions can't make a file unreadable for root")
+	}
+
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var fname = filepath.Join(root, "data", "test.txt")
+	err = os.Chmod(fname, 0)
+	if err != nil {
+		t.Fatalf("Unable to chmod %q: %s", fname, err)
+	}
+	defer os.Chmod(fname, 0644)
+
+	_, err = New(root, hasher.NewSHA256()).Validate()
+	if err == nil {
+		t.Fatalf("Validate should fail on an unreadable file")
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateComplete()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) != 1 {
+		t.Fatalf("Expected 1 discrepancy, got: %s", joinDiscrepancies(discrepancies))
+	}
+	assert.Equal(Unreadable, discrepancies[0].Kind, "discrepancy kind", t)
+	assert.Equal("data/test.txt", discrepancies[0].Path, "discrepancy path", t)
+	assert.True(discrepancies[0].Err != nil, "discrepancy has an error", t)
+}
+
+func TestValidateCompleteUnreadableManifest(t *testing.T) {
+	var root = makeTestBag(t)
+	var err = New(root, hasher.NewSHA256(), hasher.NewMD5()).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var fname = filepath.Join(root, "manifest-md5.txt")
+	err = ioutil.WriteFile(fname, []byte("not-a-manifest-line\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+	var payloadFile = filepath.Join(root, "data", "test.txt")
+	err = ioutil.WriteFile(payloadFile, []byte("corrupted!"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", payloadFile, err)
+	}
+
+	// The md5 manifest is reported, and the sha256 manifest is still checked
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewSHA256(), hasher.NewMD5()).ValidateComplete()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	var kinds = make(map[string]DiscrepancyKind)
+	for _, d := range discrepancies {
+		kinds[d.Path+" "+d.ManifestType] = d.Kind
+	}
+	assert.Equal(UnreadableManifest, kinds["manifest-md5.txt md5 manifest"], "md5 manifest discrepancy", t)
+	assert.Equal(Corrupt, kinds["data/test.txt sha256 manifest"], "payload discrepancy", t)
+	assert.Equal(Corrupt, kinds["manifest-md5.txt sha256 tag manifest"], "tag manifest discrepancy", t)
+
+	// With no readable payload manifest, there's nothing to validate against
+	fname = filepath.Join(root, "manifest-sha256.txt")
+	err = ioutil.WriteFile(fname, []byte("not-a-manifest-line\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+	_, err = New(root, hasher.NewSHA256(), hasher.NewMD5()).ValidateComplete()
+	if err == nil {
+		t.Fatalf("ValidateComplete should fail when no payload manifest can be read")
+	}
+}
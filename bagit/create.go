@@ -0,0 +1,176 @@
+package bagit
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// CreateMode tells Create how to get a source directory's files into a bag
+type CreateMode int
+
+// All modes Create supports
+const (
+	InPlace CreateMode = iota // Move the source's contents into its own "data" directory
+	Copy                      // Copy the source into a new bag's "data" directory
+	Link                      // Hard-link the source's files into a new bag's "data" directory
+)
+
+func (m CreateMode) String() string {
+	switch m {
+	case InPlace:
+		return "in place"
+	case Copy:
+		return "copy"
+	case Link:
+		return "link"
+	}
+	return fmt.Sprintf("unknown mode (%d)", int(m))
+}
+
+// Create turns the plain directory src into a bag at dst, then writes all tag
+// files using the given hashers. The bag is returned so the caller can
+// inspect its checksums. See Bag.CreateFrom for details; this is just a
+// shortcut for the common case where the bag's defaults are fine:
+//
+//	var b, err = bagit.Create(src, dst, bagit.Copy, hasher.NewSHA256())
+func Create(src, dst string, mode CreateMode, hashers ...*hasher.Hasher) (*Bag, error) {
+	var b = New(dst, hashers...)
+	return b, b.CreateFrom(src, mode)
+}
+
+// CreateFrom puts the contents of the plain directory src into the bag's
+// payload directory and then calls WriteTagFiles. This lets callers set up
+// the bag's Version, Info, Workers, etc. before creating it.
+//
+// With InPlace, src must be the bag's root, and everything in it is moved
+// into a new "data" directory under it. With Copy or Link, the bag's root
+// must not exist (though its parent must), and src is copied to "data" under
+// it via fileutil.CopyDirectory or fileutil.LinkDirectory respectively.
+//
+// If an in-place move fails partway, we try to move everything back. A
+// failed copy or link is left as-is, much like the fileutil functions.
+func (b *Bag) CreateFrom(src string, mode CreateMode) error {
+	return b.CreateFromContext(context.Background(), src, mode)
+}
+
+// CreateFromContext is CreateFrom with support for cancellation and progress
+// reporting; see WriteTagFilesContext.
+func (b *Bag) CreateFromContext(ctx context.Context, src string, mode CreateMode) error {
+	var err = b.validateHashers()
+	if err != nil {
+		return err
+	}
+
+	var absSrc, absRoot string
+	absSrc, err = filepath.Abs(src)
+	if err == nil {
+		absRoot, err = filepath.Abs(b.root)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to determine absolute paths for %q and %q: %s", src, b.root, err)
+	}
+	if !fileutil.IsDir(absSrc) {
+		return fmt.Errorf("source %q is not a directory", src)
+	}
+
+	switch mode {
+	case InPlace:
+		if absSrc != absRoot {
+			return fmt.Errorf("cannot create bag in place: source %q is not the bag root %q", src, b.root)
+		}
+		err = movePayload(absRoot)
+
+	case Copy, Link:
+		err = copyPayload(absSrc, absRoot, mode)
+
+	default:
+		return fmt.Errorf("invalid create mode %s", mode)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return b.WriteTagFilesContext(ctx)
+}
+
+// movePayload moves everything in root into root/data. Entries are moved
+// into a temporary directory first, since the source may already have a
+// "data" entry of its own.
+func movePayload(root string) error {
+	if fileutil.Exists(filepath.Join(root, "bagit.txt")) {
+		return fmt.Errorf("cannot create bag in place: %q is already a bag", root)
+	}
+
+	var infos, err = ioutil.ReadDir(root)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %s", root, err)
+	}
+
+	// This becomes the bag's data directory, so rather than TempDir, which is
+	// owner-only, we create it with the normal permissions for a directory
+	var tmp string
+	for i := 0; ; i++ {
+		tmp = filepath.Join(root, fmt.Sprintf(".bagit-data-%d", i))
+		err = os.Mkdir(tmp, 0755)
+		if !os.IsExist(err) {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("unable to create temporary payload directory in %q: %s", root, err)
+	}
+
+	var moved []string
+	for _, info := range infos {
+		err = os.Rename(filepath.Join(root, info.Name()), filepath.Join(tmp, info.Name()))
+		if err != nil {
+			break
+		}
+		moved = append(moved, info.Name())
+	}
+	if err == nil {
+		err = os.Rename(tmp, filepath.Join(root, "data"))
+	}
+	if err == nil {
+		return nil
+	}
+
+	// Put things back the best we can so the caller isn't left with a mess
+	for _, name := range moved {
+		os.Rename(filepath.Join(tmp, name), filepath.Join(root, name))
+	}
+	os.Remove(tmp)
+	return fmt.Errorf("unable to move %q into its payload directory: %s", root, err)
+}
+
+// copyPayload creates the bag's root and copies or links src into its data
+// directory
+func copyPayload(src, root string, mode CreateMode) error {
+	if !fileutil.MustNotExist(root) {
+		return fmt.Errorf("cannot create bag: destination %q already exists", root)
+	}
+
+	var err = os.Mkdir(root, 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create bag directory %q: %s", root, err)
+	}
+
+	var dataPath = filepath.Join(root, "data")
+	if mode == Link {
+		err = fileutil.LinkDirectory(src, dataPath)
+	} else {
+		err = fileutil.CopyDirectory(src, dataPath)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to %s %q to %q: %s", mode, src, dataPath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/fileutil"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// makeSourceDir returns a plain directory with a couple files, one of which
+// is named "data" to make sure in-place bagging doesn't trip over it
+func makeSourceDir(t *testing.T) string {
+	var src = filepath.Join(t.TempDir(), "src")
+	var err = os.MkdirAll(filepath.Join(src, "sub"), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(src, "data"), []byte("not a payload dir"), 0644)
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(src, "sub", "file.txt"), []byte("nested"), 0644)
+	}
+	if err != nil {
+		t.Fatalf("Unable to create source directory: %s", err)
+	}
+	return src
+}
+
+func validateCreated(t *testing.T, root string) {
+	var b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open created bag: %s", err)
+	}
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate created bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Created bag is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+	for _, p := range []string{"data/data", "data/sub/file.txt"} {
+		if !fileutil.IsFile(filepath.Join(root, p)) {
+			t.Fatalf("Created bag is missing %q", p)
+		}
+	}
+}
+
+func TestCreateInPlace(t *testing.T) {
+	var src = makeSourceDir(t)
+	var _, err = Create(src, src, InPlace, hasher.NewSHA256())
+	if err != nil {
+		t.Fatalf("Unable to create bag in place: %s", err)
+	}
+	validateCreated(t, src)
+
+	// The payload directory should get the same permissions as any other new
+	// directory, which depend on the umask
+	var expected = filepath.Join(t.TempDir(), "expected")
+	err = os.Mkdir(expected, 0755)
+	if err != nil {
+		t.Fatalf("Unable to create %q: %s", expected, err)
+	}
+	var want, got os.FileInfo
+	want, _ = os.Stat(expected)
+	got, err = os.Stat(filepath.Join(src, "data"))
+	if err != nil {
+		t.Fatalf("Unable to stat payload directory: %s", err)
+	}
+	assert.Equal(want.Mode().Perm(), got.Mode().Perm(), "payload directory permissions", t)
+
+	_, err = Create(src, src, InPlace, hasher.NewSHA256())
+	if err == nil {
+		t.Fatalf("Creating a bag in place from an existing bag should be an error")
+	}
+}
+
+func TestCreateCopy(t *testing.T) {
+	for _, mode := range []CreateMode{Copy, Link} {
+		var src = makeSourceDir(t)
+		var dst = filepath.Join(filepath.Dir(src), "bag")
+		var b = New(dst, hasher.NewMD5())
+		b.Info.Set(SourceOrganization, "UO")
+		var err = b.CreateFrom(src, mode)
+		if err != nil {
+			t.Fatalf("Unable to create bag (%s): %s", mode, err)
+		}
+		validateCreated(t, dst)
+
+		if !fileutil.IsFile(filepath.Join(src, "sub", "file.txt")) {
+			t.Fatalf("Source should be untouched (%s)", mode)
+		}
+		if !fileutil.IsFile(filepath.Join(dst, "bag-info.txt")) {
+			t.Fatalf("bag-info.txt should have been written (%s)", mode)
+		}
+
+		err = b.CreateFrom(src, mode)
+		if err == nil {
+			t.Fatalf("Creating a bag over an existing directory should be an error (%s)", mode)
+		}
+	}
+}
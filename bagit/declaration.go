@@ -0,0 +1,126 @@
+package bagit
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// Supported BagIt versions. Version097 is the long-standing draft spec
+// (draft-kunze-bagit-14), and is what we default to for backward
+// compatibility. Version10 is the final spec, RFC 8493.
+const (
+	Version097 = "0.97"
+	Version10  = "1.0"
+)
+
+// The only tag file encoding we support
+const tagFileEncoding = "UTF-8"
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// isSupportedVersion returns true if we know how to read and write bags of
+// the given version
+func isSupportedVersion(v string) bool {
+	return v == Version097 || v == Version10
+}
+
+func (b *Bag) declarationFilename() string {
+	return filepath.Join(b.root, "bagit.txt")
+}
+
+func (b *Bag) writeBagitFile() error {
+	if !isSupportedVersion(b.Version) {
+		return fmt.Errorf("unsupported BagIt version %q", b.Version)
+	}
+
+	var f = fileutil.NewSafeFile(b.declarationFilename())
+	fmt.Fprintf(f, "BagIt-Version: %s\nTag-File-Character-Encoding: %s\n", b.Version, tagFileEncoding)
+	return f.Close()
+}
+
+// ReadDeclaration parses the bag's "bagit.txt" and sets b.Version to the
+// version the bag declares. An error is returned if the file is missing or
+// malformed, or if it declares a version or tag file encoding we don't
+// support.
+//
+// This is called automatically by ReadManifests (and therefore Validate), so
+// a bag with a missing or invalid declaration is never considered valid.
+func (b *Bag) ReadDeclaration() error {
+	return b.readDeclarationFrom(ioutil.ReadFile)
+}
+
+// readDeclarationFrom is ReadDeclaration with a custom function for reading
+// the file
+func (b *Bag) readDeclarationFrom(readFile func(string) ([]byte, error)) error {
+	var fname = b.declarationFilename()
+	var data, err = readFile(fname)
+	if err != nil {
+		return fmt.Errorf("unable to read bag declaration %q: %w", fname, err)
+	}
+
+	var hasBOM = bytes.HasPrefix(data, utf8BOM)
+	var tags []Tag
+	tags, err = parseTags(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("unable to parse bag declaration %q: %w", fname, err)
+	}
+
+	var version, encoding string
+	for _, t := range tags {
+		switch t.Label {
+		case "BagIt-Version":
+			version = t.Value
+		case "Tag-File-Character-Encoding":
+			encoding = t.Value
+		default:
+			return fmt.Errorf("invalid label in bag declaration %q: %q", fname, t.Label)
+		}
+	}
+
+	if version == "" {
+		return fmt.Errorf("bag declaration %q has no BagIt-Version", fname)
+	}
+	if !isSupportedVersion(version) {
+		return fmt.Errorf("bag declaration %q: unsupported BagIt version %q", fname, version)
+	}
+	if encoding == "" {
+		return fmt.Errorf("bag declaration %q has no Tag-File-Character-Encoding", fname)
+	}
+	if !strings.EqualFold(encoding, tagFileEncoding) {
+		return fmt.Errorf("bag declaration %q: unsupported tag file encoding %q", fname, encoding)
+	}
+
+	// RFC 8493 explicitly forbids a byte-order mark; the older spec was silent
+	// on the matter, so we let it slide there
+	if hasBOM && version == Version10 {
+		return fmt.Errorf("bag declaration %q must not contain a byte-order mark", fname)
+	}
+
+	b.Version = version
+	return nil
+}
+
+// CR, LF, and % must be percent-encoded in manifest and fetch paths. The
+// encoder has to handle % first, which strings.Replacer does for us since it
+// never re-scans replaced text.
+var pathEncoder = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+var pathDecoder = strings.NewReplacer("%25", "%", "%0D", "\r", "%0d", "\r", "%0A", "\n", "%0a", "\n")
+
+// encodePath returns the path as it must be written to a manifest or
+// fetch.txt. RFC 8493 requires percent-encoding, and while the 0.97 draft is
+// less clear, other BagIt tools encode paths in 0.97 bags as well, and
+// there's no other way to store a line break.
+func encodePath(path string) string {
+	return pathEncoder.Replace(path)
+}
+
+// decodePath returns the real path for a path read from a manifest or
+// fetch.txt
+func decodePath(path string) string {
+	return pathDecoder.Replace(path)
+}
@@ -0,0 +1,84 @@
+package bagit
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DiscrepancyKind tells us what sort of problem a Discrepancy describes
+type DiscrepancyKind int
+
+// All kinds of discrepancies validation can report
+const (
+	Missing            DiscrepancyKind = iota + 1 // File is listed in a manifest, but isn't on disk
+	Corrupt                                       // File's checksum doesn't match the manifest
+	Extra                                         // File is on disk, but isn't listed in a manifest
+	UnreadableManifest                            // A manifest exists, but can't be read or parsed
+	OxumMismatch                                  // Payload-Oxum doesn't match the payload on disk
+	Unreadable                                    // A file exists, but couldn't be read
+)
+
+func (k DiscrepancyKind) String() string {
+	switch k {
+	case Missing:
+		return "missing file"
+	case Corrupt:
+		return "corrupt file"
+	case Extra:
+		return "extra file"
+	case UnreadableManifest:
+		return "unreadable manifest"
+	case OxumMismatch:
+		return "payload oxum mismatch"
+	case Unreadable:
+		return "unreadable file"
+	}
+	return fmt.Sprintf("unknown discrepancy (%d)", int(k))
+}
+
+// A Discrepancy is a single problem found when validating a bag
+type Discrepancy struct {
+	Kind         DiscrepancyKind
+	Path         string // Path relative to the bag root of the file with the problem
+	ManifestType string // Describes the manifest, e.g., "manifest" or "sha256 tag manifest"
+	Expected     string // Checksum (or Payload-Oxum) the bag claims we should have
+	Actual       string // Checksum (or Payload-Oxum) we computed
+	Err          error  // For unreadable manifests and files, what went wrong
+}
+
+// String returns a human-readable description of the discrepancy
+func (d *Discrepancy) String() string {
+	switch d.Kind {
+	case Missing:
+		return fmt.Sprintf("%s: %q (%s lists the file, but it is not present on disk)", d.Kind, d.Path, d.ManifestType)
+	case Corrupt:
+		return fmt.Sprintf("%s: %q (%s checksum was %q, actual checksum was %q", d.Kind, d.Path, d.ManifestType, d.Expected, d.Actual)
+	case Extra:
+		return fmt.Sprintf("%s: %q (%s does not list the file, but it is present on disk)", d.Kind, d.Path, d.ManifestType)
+	case UnreadableManifest:
+		return fmt.Sprintf("%s: %q (%s could not be read: %s)", d.Kind, d.Path, d.ManifestType, d.Err)
+	case OxumMismatch:
+		return fmt.Sprintf("%s: %q (%s was %q, actual payload oxum was %q)", d.Kind, d.Path, d.ManifestType, d.Expected, d.Actual)
+	case Unreadable:
+		return fmt.Sprintf("%s: %q (%s)", d.Kind, d.Path, d.Err)
+	}
+	return fmt.Sprintf("%s: %q", d.Kind, d.Path)
+}
+
+func missingFile(manifestType, path, expected string) *Discrepancy {
+	return &Discrepancy{Kind: Missing, Path: path, ManifestType: manifestType, Expected: expected}
+}
+
+func extraFile(manifestType, path, actual string) *Discrepancy {
+	return &Discrepancy{Kind: Extra, Path: path, ManifestType: manifestType, Actual: actual}
+}
+
+// sortDiscrepancies sorts by path, then kind, so reports are predictable
+func sortDiscrepancies(list []*Discrepancy) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Path != list[j].Path {
+			return list[i].Path < list[j].Path
+		}
+		return list[i].Kind < list[j].Kind
+	})
+}
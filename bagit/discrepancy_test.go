@@ -0,0 +1,101 @@
+package bagit
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func joinDiscrepancies(list []*Discrepancy) string {
+	var s []string
+	for _, d := range list {
+		s = append(s, d.String())
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestDiscrepancyString(t *testing.T) {
+	var tests = []struct {
+		d        *Discrepancy
+		expected string
+	}{
+		{
+			&Discrepancy{Kind: Missing, Path: "data/a.txt", ManifestType: "manifest"},
+			`missing file: "data/a.txt" (manifest lists the file, but it is not present on disk)`,
+		},
+		{
+			&Discrepancy{Kind: Corrupt, Path: "data/a.txt", ManifestType: "tag manifest", Expected: "abc", Actual: "def"},
+			`corrupt file: "data/a.txt" (tag manifest checksum was "abc", actual checksum was "def"`,
+		},
+		{
+			&Discrepancy{Kind: Extra, Path: "data/a.txt", ManifestType: "manifest"},
+			`extra file: "data/a.txt" (manifest does not list the file, but it is present on disk)`,
+		},
+		{
+			&Discrepancy{Kind: UnreadableManifest, Path: "manifest-md5.txt", ManifestType: "md5 manifest", Err: errors.New("bad line")},
+			`unreadable manifest: "manifest-md5.txt" (md5 manifest could not be read: bad line)`,
+		},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(tt.expected, tt.d.String(), tt.d.Kind.String(), t)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	var manifest = []*FileChecksum{
+		{Path: "data/b.txt", Checksum: "bbb"},
+		{Path: "data/a.txt", Checksum: "aaa"},
+		{Path: "data/c.txt", Checksum: "ccc"},
+	}
+	var actual = []*FileChecksum{
+		{Path: "data/c.txt", Checksum: "ccc"},
+		{Path: "data/a.txt", Checksum: "AAA"},
+		{Path: "data/d.txt", Checksum: "ddd"},
+	}
+
+	var list = Compare("manifest", manifest, actual)
+	assert.Equal(3, len(list), "discrepancy count", t)
+
+	var expected = []*Discrepancy{
+		{Kind: Corrupt, Path: "data/a.txt", ManifestType: "manifest", Expected: "aaa", Actual: "AAA"},
+		{Kind: Missing, Path: "data/b.txt", ManifestType: "manifest", Expected: "bbb"},
+		{Kind: Extra, Path: "data/d.txt", ManifestType: "manifest", Actual: "ddd"},
+	}
+	for i := range expected {
+		assert.Equal(*expected[i], *list[i], "discrepancy "+expected[i].Path, t)
+	}
+}
+
+func TestValidateUnreadableManifest(t *testing.T) {
+	var root = makeTestBag(t)
+	var err = New(root, hasher.NewMD5()).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var fname = filepath.Join(root, "tagmanifest-md5.txt")
+	err = ioutil.WriteFile(fname, []byte("this is not a manifest line\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewMD5()).Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "discrepancy count", t)
+	assert.Equal(UnreadableManifest, discrepancies[0].Kind, "discrepancy kind", t)
+	assert.Equal("tagmanifest-md5.txt", discrepancies[0].Path, "discrepancy path", t)
+
+	err = New(root, hasher.NewMD5()).ReadManifests()
+	if err == nil {
+		t.Fatalf("ReadManifests should return an error for an unparseable manifest")
+	}
+}
@@ -0,0 +1,303 @@
+package bagit
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// FetchItem is a single entry in a bag's "fetch.txt": a payload file which
+// isn't in the bag, and the URL from which it can be retrieved
+type FetchItem struct {
+	URL    string
+	Length int64  // Size in bytes, or -1 if unknown
+	Path   string // Path relative to the bag's root, e.g., "data/big.tif"
+}
+
+// A Fetcher retrieves the data for a fetch.txt URL
+type Fetcher interface {
+	Fetch(url string) (io.ReadCloser, error)
+}
+
+// FetcherFunc is an adapter to allow an ordinary function to be a Fetcher
+type FetcherFunc func(url string) (io.ReadCloser, error)
+
+// Fetch calls f(url)
+func (f FetcherFunc) Fetch(url string) (io.ReadCloser, error) {
+	return f(url)
+}
+
+// FileFetcher is a Fetcher which only handles "file://" URLs, reading the
+// data from the local filesystem. This is mostly useful for bags whose large
+// files live on a shared mount, and for testing.
+type FileFetcher struct{}
+
+// Fetch opens the local file the URL refers to
+func (FileFetcher) Fetch(rawURL string) (io.ReadCloser, error) {
+	var u, err = url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if u.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported URL %q: only file:// URLs can be fetched", rawURL)
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("unsupported URL %q: file:// URLs must be local", rawURL)
+	}
+
+	return os.Open(filepath.FromSlash(u.Path))
+}
+
+func (b *Bag) fetchFilename() string {
+	return filepath.Join(b.root, "fetch.txt")
+}
+
+// AddFetchItem adds an entry to the bag's fetch list. Pass -1 for length if
+// the file's size isn't known. p must be relative to the bag's root and in
+// the payload directory, e.g., "data/big.tif".
+//
+// When tag files are written, a fetch item's file may be missing from disk,
+// but its checksums must then be provided by the bag's Cache so the manifests
+// can list it.
+func (b *Bag) AddFetchItem(url string, length int64, p string) {
+	b.FetchItems = append(b.FetchItems, &FetchItem{URL: url, Length: length, Path: p})
+}
+
+// ReadFetchFile parses the bag's "fetch.txt" into b.FetchItems. The file is
+// optional, so if it doesn't exist b.FetchItems is simply emptied.
+func (b *Bag) ReadFetchFile() error {
+	b.FetchItems = nil
+
+	var fname = b.fetchFilename()
+	var data, err = ioutil.ReadFile(fname)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("unable to read fetch file %q: %w", fname, err)
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		var item, err = parseFetchLine(line)
+		if err != nil {
+			return fmt.Errorf("invalid fetch file line in %q: %q: %s", fname, line, err)
+		}
+		b.FetchItems = append(b.FetchItems, item)
+	}
+
+	return nil
+}
+
+// parseFetchLine splits a "URL LENGTH FILENAME" line. The filename is
+// everything after the length, so it may contain whitespace.
+func parseFetchLine(line string) (*FetchItem, error) {
+	var u, rest, ok = cutField(line)
+	if !ok {
+		return nil, fmt.Errorf("missing length and filename")
+	}
+	var length, fname string
+	length, fname, ok = cutField(rest)
+	if !ok {
+		return nil, fmt.Errorf("missing filename")
+	}
+
+	var item = &FetchItem{URL: u, Length: -1, Path: decodePath(fname)}
+	if length != "-" {
+		var n, err = strconv.ParseInt(length, 10, 64)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid length %q", length)
+		}
+		item.Length = n
+	}
+
+	return item, validateFetchPath(item.Path)
+}
+
+// validateFetchPath ensures a fetch item can't write outside the payload
+func validateFetchPath(p string) error {
+	if path.IsAbs(p) || path.Clean(p) != p || !strings.HasPrefix(p, "data/") {
+		return fmt.Errorf("invalid path %q: fetched files must be under data/", p)
+	}
+	return nil
+}
+
+// writeFetchFile writes b.FetchItems to fetch.txt if there are any
+func (b *Bag) writeFetchFile() error {
+	if len(b.FetchItems) == 0 {
+		return nil
+	}
+
+	var f = fileutil.NewSafeFile(b.fetchFilename())
+	for _, item := range b.FetchItems {
+		var p = encodePath(item.Path)
+		var err = validateFetchPath(item.Path)
+		if err == nil && strings.ContainsAny(item.URL, " \t\r\n") {
+			err = fmt.Errorf("invalid URL %q: whitespace isn't allowed", item.URL)
+		}
+		if err != nil {
+			f.Cancel()
+			return fmt.Errorf("error writing fetch file: %s", err)
+		}
+
+		var length = "-"
+		if item.Length >= 0 {
+			length = strconv.FormatInt(item.Length, 10)
+		}
+		fmt.Fprintf(f, "%s %s %s\n", item.URL, length, p)
+	}
+
+	var err = f.Close()
+	if err != nil {
+		return fmt.Errorf("error writing fetch file: %s", err)
+	}
+	return nil
+}
+
+// addFetchSums adds checksums for any fetch items which aren't on disk to
+// b.ActualChecksums so they end up in the manifests. Since we can't read the
+// files, their checksums must come from the bag's Cache.
+func (b *Bag) addFetchSums() error {
+	var present = make(map[string]bool)
+	for _, ck := range b.payloadSums() {
+		present[ck.Path] = true
+	}
+
+	for _, item := range b.FetchItems {
+		if present[item.Path] {
+			continue
+		}
+		for _, h := range b.Hashers {
+			var sum, ok = b.getCachedSum(h.Name, item.Path)
+			if !ok {
+				return fmt.Errorf("fetch item %q is not on disk, and the bag's cache has no %s checksum for it", item.Path, h.Name)
+			}
+			b.ActualChecksums[h.Name] = append(b.ActualChecksums[h.Name], &FileChecksum{Path: item.Path, Checksum: sum})
+		}
+		present[item.Path] = true
+	}
+
+	for _, sums := range b.ActualChecksums {
+		sortSums(sums)
+	}
+	return nil
+}
+
+// Complete downloads every file listed in the bag's fetch.txt which isn't
+// already on disk, using f to retrieve the data, and then validates the bag.
+// Each download is checked against its declared length (if any) and the
+// checksums in every payload manifest before it's moved into place, so a bad
+// download never ends up in the bag.
+//
+// An error is returned if any fetch fails or doesn't match the manifests, in
+// addition to the failures Validate can return. Files fetched before an error
+// are left in place.
+func (b *Bag) Complete(f Fetcher) (discrepancies []*Discrepancy, err error) {
+	err = b.ReadManifests()
+	if err == nil {
+		err = b.ReadFetchFile()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range b.FetchItems {
+		var dest = filepath.Join(b.root, filepath.FromSlash(item.Path))
+		if fileutil.Exists(dest) {
+			continue
+		}
+		err = b.fetch(f, item, dest)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Validate()
+}
+
+// fetch retrieves a single fetch item, verifying it before moving it to dest
+func (b *Bag) fetch(f Fetcher, item *FetchItem, dest string) error {
+	var expected = make(map[string]string)
+	for _, h := range b.Hashers {
+		for _, ck := range b.ManifestChecksums[h.Name] {
+			if ck.Path == item.Path {
+				expected[h.Name] = ck.Checksum
+			}
+		}
+		if expected[h.Name] == "" {
+			return fmt.Errorf("fetch item %q is not listed in %s", item.Path, filepath.Base(b.manifestFilename(h)))
+		}
+	}
+
+	var err = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create directory for %q: %s", item.Path, err)
+	}
+
+	var r io.ReadCloser
+	r, err = f.Fetch(item.URL)
+	if err != nil {
+		return fmt.Errorf("unable to fetch %q from %q: %s", item.Path, item.URL, err)
+	}
+	defer r.Close()
+
+	var tmp *os.File
+	tmp, err = fileutil.TempFile(filepath.Dir(dest), ".fetch-", "")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file for %q: %s", item.Path, err)
+	}
+	var tmpName = tmp.Name()
+
+	var n int64
+	var sums map[string]string
+	n, sums, err = copyAndHash(tmp, r, b.Hashers)
+	if err == nil {
+		// TempFile is owner-only, but this is becoming a normal payload file
+		err = tmp.Chmod(0644)
+	}
+	var cerr = tmp.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err == nil && item.Length >= 0 && n != item.Length {
+		err = fmt.Errorf("expected %d bytes, got %d", item.Length, n)
+	}
+	for _, h := range b.Hashers {
+		if err == nil && sums[h.Name] != expected[h.Name] {
+			err = fmt.Errorf("%s checksum was %q, expected %q", h.Name, sums[h.Name], expected[h.Name])
+		}
+	}
+	if err == nil {
+		err = os.Rename(tmpName, dest)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("unable to fetch %q from %q: %s", item.Path, item.URL, err)
+	}
+
+	return nil
+}
+
+// copyAndHash copies r to w, computing checksums for all hashers along the way
+func copyAndHash(w io.Writer, r io.Reader, hashers []*hasher.Hasher) (int64, map[string]string, error) {
+	var mh = hasher.NewMulti(hashers...)
+	mh.Reset()
+	var n, err = io.Copy(io.MultiWriter(w, mh), r)
+	if err != nil {
+		return n, nil, err
+	}
+	return n, mh.Sums(), nil
+}
@@ -0,0 +1,155 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/fileutil"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// mapCache is a simple in-memory AlgoCacher for pre-computing sums
+type mapCache map[string]string
+
+func (c mapCache) GetSum(path string) (string, bool)   { return c.GetAlgoSum("", path) }
+func (c mapCache) SetSum(path, value string)           { c.SetAlgoSum("", path, value) }
+func (c mapCache) SetAlgoSum(algo, path, value string) { c[algo+":"+path] = value }
+func (c mapCache) GetAlgoSum(algo, path string) (string, bool) {
+	var v, ok = c[algo+":"+path]
+	return v, ok
+}
+
+// makeHoleyBag creates a bag whose "data/big.txt" is only available from a
+// file outside the bag, and returns the bag's root and the remote file's path
+func makeHoleyBag(t *testing.T) (root, remote string) {
+	root = makeTestBag(t)
+	remote = filepath.Join(t.TempDir(), "big.txt")
+	var err = ioutil.WriteFile(remote, []byte("pretend this is huge"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", remote, err)
+	}
+
+	var b = New(root, hasher.NewMD5(), hasher.NewSHA256())
+	b.Version = Version10
+	var md5, _ = hasher.NewMD5().FileSum(remote)
+	var sha, _ = hasher.NewSHA256().FileSum(remote)
+	b.Cache = mapCache{"md5:data/big.txt": md5, "sha256:data/big.txt": sha}
+	b.Info.Set(SourceOrganization, "UO")
+	b.AddFetchItem("file://"+filepath.ToSlash(remote), 20, "data/big.txt")
+
+	err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	return root, remote
+}
+
+func TestWriteHoleyBag(t *testing.T) {
+	var root, remote = makeHoleyBag(t)
+
+	var raw, err = ioutil.ReadFile(filepath.Join(root, "fetch.txt"))
+	if err != nil {
+		t.Fatalf("Unable to read fetch.txt: %s", err)
+	}
+	assert.Equal("file://"+filepath.ToSlash(remote)+" 20 data/big.txt\n", string(raw), "fetch.txt", t)
+
+	raw, err = ioutil.ReadFile(filepath.Join(root, "manifest-md5.txt"))
+	if err != nil {
+		t.Fatalf("Unable to read manifest: %s", err)
+	}
+	if !strings.Contains(string(raw), "  data/big.txt\n") {
+		t.Fatalf("Manifest should list the fetched file, but got %q", raw)
+	}
+
+	var b = New(root)
+	err = b.ReadBagInfo()
+	assert.NilError(err, "reading bag info", t)
+	assert.Equal("43.3", b.Info.Get(PayloadOxum), "Payload-Oxum", t)
+
+	// The bag isn't complete yet, so it must not be valid
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewMD5(), hasher.NewSHA256()).Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) == 0 {
+		t.Fatalf("A holey bag shouldn't be valid")
+	}
+
+	// Without the checksums, we can't write a holey bag
+	var root2 = makeTestBag(t)
+	b = New(root2, hasher.NewMD5())
+	b.AddFetchItem("file://"+filepath.ToSlash(remote), 20, "data/big.txt")
+	err = b.WriteTagFiles()
+	if err == nil {
+		t.Fatalf("Writing a holey bag with no precomputed checksums should fail")
+	}
+}
+
+func TestComplete(t *testing.T) {
+	var root, _ = makeHoleyBag(t)
+
+	var b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Complete(FileFetcher{})
+	if err != nil {
+		t.Fatalf("Unable to complete bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+
+	var raw []byte
+	raw, err = ioutil.ReadFile(filepath.Join(root, "data", "big.txt"))
+	assert.NilError(err, "reading fetched file", t)
+	assert.Equal("pretend this is huge", string(raw), "fetched file contents", t)
+
+	var info os.FileInfo
+	info, err = os.Stat(filepath.Join(root, "data", "big.txt"))
+	assert.NilError(err, "stat on fetched file", t)
+	assert.Equal(os.FileMode(0644), info.Mode().Perm(), "fetched file permissions", t)
+}
+
+func TestCompleteBadData(t *testing.T) {
+	var root, remote = makeHoleyBag(t)
+	var err = ioutil.WriteFile(remote, []byte("pretend this is HUGE"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", remote, err)
+	}
+
+	var b *Bag
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	_, err = b.Complete(FileFetcher{})
+	if err == nil {
+		t.Fatalf("Fetching a file with the wrong checksum should fail")
+	}
+	if fileutil.Exists(filepath.Join(root, "data", "big.txt")) {
+		t.Fatalf("A bad fetch shouldn't leave a file behind")
+	}
+
+	err = ioutil.WriteFile(remote, []byte("short"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", remote, err)
+	}
+	_, err = b.Complete(FileFetcher{})
+	if err == nil || !strings.Contains(err.Error(), "expected 20 bytes") {
+		t.Fatalf("Fetching a file with the wrong length should fail, got %v", err)
+	}
+
+	var infos []os.FileInfo
+	infos, err = ioutil.ReadDir(filepath.Join(root, "data"))
+	assert.NilError(err, "reading data dir", t)
+	assert.Equal(2, len(infos), "files in data dir after failed fetches", t)
+}
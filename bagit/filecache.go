@@ -0,0 +1,188 @@
+package bagit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// fileCacheEntry holds a file's checksums along with the size and mtime it
+// had when they were computed
+type fileCacheEntry struct {
+	Size    int64             `json:"size"`
+	ModTime int64             `json:"mtime"`
+	Sums    map[string]string `json:"sums"`
+}
+
+// FileCache is an AlgoCacher which persists checksums to a JSON file, so
+// re-bagging a large tree only has to hash the files which changed. Each
+// entry stores the file's size and modification time, and is thrown away if
+// either has changed when it's next looked up.
+//
+// Files which can't be found on disk, such as fetch items in a holey bag,
+// can't be checked for changes, so their entries are always trusted.
+//
+// Nothing is written to disk until Save is called.
+type FileCache struct {
+	m       sync.Mutex
+	path    string
+	root    string
+	entries map[string]*fileCacheEntry
+}
+
+// NewFileCache returns a FileCache for the bag at root, stored in the file at
+// path. If the file exists, its entries are loaded. The cache file shouldn't
+// live inside the bag, or it'll end up in the bag's payload or tag manifests.
+func NewFileCache(path, root string) (*FileCache, error) {
+	var c = &FileCache{path: path, root: root, entries: make(map[string]*fileCacheEntry)}
+	var data, err = ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read cache file %q: %w", path, err)
+	}
+
+	err = json.Unmarshal(data, &c.entries)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse cache file %q: %w", path, err)
+	}
+	return c, nil
+}
+
+// stat returns the size and mtime of the file at path (relative to the
+// cache's root). ok is false if the file can't be found.
+func (c *FileCache) stat(path string) (size, mtime int64, ok bool) {
+	var info, err = os.Stat(filepath.Join(c.root, filepath.FromSlash(path)))
+	if err != nil {
+		return -1, 0, false
+	}
+	return info.Size(), info.ModTime().UnixNano(), true
+}
+
+// entry returns the path's entry if it's still valid, removing it if not
+func (c *FileCache) entry(path string) *fileCacheEntry {
+	var e = c.entries[path]
+	if e == nil {
+		return nil
+	}
+
+	var size, mtime, ok = c.stat(path)
+	if ok && (size != e.Size || mtime != e.ModTime) {
+		delete(c.entries, path)
+		return nil
+	}
+	return e
+}
+
+// GetAlgoSum implements AlgoCacher
+func (c *FileCache) GetAlgoSum(algo, path string) (string, bool) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var e = c.entry(path)
+	if e == nil {
+		return "", false
+	}
+	var sum, ok = e.Sums[algo]
+	return sum, ok
+}
+
+// SetAlgoSum implements AlgoCacher. The entry is tied to the file's current
+// size and mtime, so a file which changed while it was being hashed would be
+// cached with the wrong checksum; bags avoid this by reading the file's info
+// before hashing it, then passing that to setAlgoSumInfo instead.
+func (c *FileCache) SetAlgoSum(algo, path, value string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	var size, mtime, _ = c.stat(path)
+	c.set(algo, path, value, size, mtime)
+}
+
+// setAlgoSumInfo implements infoCacher
+func (c *FileCache) setAlgoSumInfo(algo, path, value string, info os.FileInfo) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.set(algo, path, value, info.Size(), info.ModTime().UnixNano())
+}
+
+// set stores a checksum for a file with the given size and mtime, replacing
+// the file's entry if it was for a different size or mtime
+func (c *FileCache) set(algo, path, value string, size, mtime int64) {
+	var e = c.entries[path]
+	if e == nil || e.Size != size || e.ModTime != mtime {
+		e = &fileCacheEntry{Size: size, ModTime: mtime, Sums: make(map[string]string)}
+		c.entries[path] = e
+	}
+	e.Sums[algo] = value
+}
+
+// GetSum implements Cacher. Bags always use GetAlgoSum, so this is only
+// useful to code which calls the cache directly, and stores the checksum
+// with an empty algorithm name.
+func (c *FileCache) GetSum(path string) (string, bool) {
+	return c.GetAlgoSum("", path)
+}
+
+// SetSum implements Cacher; see GetSum
+func (c *FileCache) SetSum(path, value string) {
+	c.SetAlgoSum("", path, value)
+}
+
+// Save writes the cache to disk. Entries for files which have been removed
+// since they were cached are dropped first, so the cache doesn't grow forever
+// as a bag's payload changes. Entries for files which weren't on disk when
+// they were cached, such as fetch items, are kept.
+//
+// The cache is written to a temp file next to the cache file and then renamed
+// into place, so a failed save leaves the previous cache file alone.
+func (c *FileCache) Save() error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	for path, e := range c.entries {
+		var _, _, ok = c.stat(path)
+		if e.Size >= 0 && !ok {
+			delete(c.entries, path)
+		}
+	}
+
+	var data, err = json.Marshal(c.entries)
+	if err != nil {
+		return fmt.Errorf("unable to encode cache: %w", err)
+	}
+
+	var f *os.File
+	f, err = fileutil.TempFile(filepath.Dir(c.path), "."+filepath.Base(c.path)+"-", "")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file for cache file %q: %w", c.path, err)
+	}
+	var tmpName = f.Name()
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	var cerr = f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, c.path)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return fmt.Errorf("unable to write cache file %q: %w", c.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,162 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestFileCache(t *testing.T) {
+	var root = makeTestBag(t)
+	var cachePath = filepath.Join(t.TempDir(), "cache.json")
+	var c, err = NewFileCache(cachePath, root)
+	if err != nil {
+		t.Fatalf("Unable to create cache: %s", err)
+	}
+
+	var b = New(root, hasher.NewMD5())
+	b.Cache = c
+	err = b.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums: %s", err)
+	}
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("Unable to save cache: %s", err)
+	}
+
+	c, err = NewFileCache(cachePath, root)
+	if err != nil {
+		t.Fatalf("Unable to reload cache: %s", err)
+	}
+	var sum, ok = c.GetAlgoSum("md5", "data/test.txt")
+	assert.True(ok, "reloaded cache has data/test.txt", t)
+	assert.Equal("b05403212c66bdc8ccc597fedf6cd5fe", sum, "cached checksum", t)
+
+	// Prove the cache is used by giving it a bogus value
+	c.SetAlgoSum("md5", "data/test.txt", "bogus")
+	b = New(root, hasher.NewMD5())
+	b.Cache = c
+	err = b.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums: %s", err)
+	}
+	assert.Equal("bogus", b.ActualChecksums["md5"][1].Checksum, "checksum from cache", t)
+
+	// Changing the file's size has to invalidate the entry
+	err = ioutil.WriteFile(filepath.Join(root, "data", "test.txt"), []byte("changed\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to change test file: %s", err)
+	}
+	_, ok = c.GetAlgoSum("md5", "data/test.txt")
+	assert.False(ok, "changed file's cache entry should be invalid", t)
+}
+
+func TestValidateIgnoresFileCache(t *testing.T) {
+	var root = makeTestBag(t)
+	var c, err = NewFileCache(filepath.Join(t.TempDir(), "cache.json"), root)
+	if err != nil {
+		t.Fatalf("Unable to create cache: %s", err)
+	}
+
+	var b = New(root, hasher.NewMD5())
+	b.Cache = c
+	err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	// Flip a byte without changing the file's size or mtime, so the cache
+	// still considers its entry valid
+	var fname = filepath.Join(root, "data", "test.txt")
+	var info, _ = os.Stat(fname)
+	var data, _ = ioutil.ReadFile(fname)
+	data[0] ^= 0xff
+	err = ioutil.WriteFile(fname, data, 0644)
+	if err == nil {
+		err = os.Chtimes(fname, info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		t.Fatalf("Unable to corrupt %q: %s", fname, err)
+	}
+	var _, ok = c.GetAlgoSum("md5", "data/test.txt")
+	assert.True(ok, "corrupted file's cache entry is still valid", t)
+
+	for name, validate := range map[string]func() ([]*Discrepancy, error){
+		"Validate":         b.Validate,
+		"ValidateComplete": b.ValidateComplete,
+	} {
+		var discrepancies, err = validate()
+		if err != nil {
+			t.Fatalf("%s: unable to validate: %s", name, err)
+		}
+		if len(discrepancies) != 1 {
+			t.Fatalf("%s: expected 1 discrepancy, got: %s", name, joinDiscrepancies(discrepancies))
+		}
+		assert.Equal(Corrupt, discrepancies[0].Kind, name+" discrepancy kind", t)
+		assert.Equal("data/test.txt", discrepancies[0].Path, name+" discrepancy path", t)
+	}
+}
+
+func TestFileCacheChangedWhileHashing(t *testing.T) {
+	var root = makeTestBag(t)
+	var c, err = NewFileCache(filepath.Join(t.TempDir(), "cache.json"), root)
+	if err != nil {
+		t.Fatalf("Unable to create cache: %s", err)
+	}
+
+	// The file is changed after it's been stat'ed but before its checksum is
+	// stored, as if it were written to while being hashed
+	var fname = filepath.Join(root, "data", "test.txt")
+	var info, _ = os.Stat(fname)
+	err = ioutil.WriteFile(fname, []byte("changed while hashing\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to change %q: %s", fname, err)
+	}
+	var b = New(root, hasher.NewMD5())
+	b.Cache = c
+	b.setCachedSum("md5", "data/test.txt", "b05403212c66bdc8ccc597fedf6cd5fe", info)
+
+	var _, ok = c.GetAlgoSum("md5", "data/test.txt")
+	assert.False(ok, "checksum cached with the pre-hash size should be invalid", t)
+}
+
+func TestFileCacheSavePrunes(t *testing.T) {
+	var root = makeTestBag(t)
+	var cachePath = filepath.Join(t.TempDir(), "cache.json")
+	var c, err = NewFileCache(cachePath, root)
+	if err != nil {
+		t.Fatalf("Unable to create cache: %s", err)
+	}
+
+	var b = New(root, hasher.NewMD5())
+	b.Cache = c
+	err = b.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums: %s", err)
+	}
+	c.SetAlgoSum("md5", "data/fetched.txt", "d41d8cd98f00b204e9800998ecf8427e")
+	err = os.Remove(filepath.Join(root, "data", "test.txt"))
+	if err != nil {
+		t.Fatalf("Unable to remove test file: %s", err)
+	}
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("Unable to save cache: %s", err)
+	}
+
+	c, err = NewFileCache(cachePath, root)
+	if err != nil {
+		t.Fatalf("Unable to reload cache: %s", err)
+	}
+	assert.Equal(2, len(c.entries), "cache entries", t)
+	assert.True(c.entries["data/test.txt"] == nil, "removed file's entry should be pruned", t)
+	assert.True(c.entries["data/fetched.txt"] != nil, "entry for a file never on disk should be kept", t)
+
+	var leftovers, _ = filepath.Glob(filepath.Join(filepath.Dir(cachePath), ".cache.json-*"))
+	assert.Equal(0, len(leftovers), "leftover temp files", t)
+}
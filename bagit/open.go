@@ -0,0 +1,118 @@
+package bagit
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// Open returns a Bag for an existing bag at root, with a hasher for each
+// "manifest-[hashtype].txt" file present. The bag declaration is read, so
+// the returned bag's Version reflects the bag on disk. This is the easiest
+// way to validate a bag when you don't know ahead of time which algorithms
+// it uses:
+//
+//	var b, err = bagit.Open(path)
+//	if err == nil {
+//		discrepancies, err = b.Validate()
+//	}
+//
+// An error is returned if there are no manifests, if any manifest or tag
+// manifest uses an algorithm the hasher package doesn't support, or if a tag
+// manifest exists with no matching payload manifest.
+func Open(root string) (*Bag, error) {
+	var b = New(root)
+	var algos, tagAlgos, err = b.ManifestAlgos()
+	if err != nil {
+		return nil, err
+	}
+
+	b.Hashers, err = algoHashers(root, algos, tagAlgos)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.ReadDeclaration()
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// algoHashers returns a hasher for each payload manifest algorithm, making
+// sure every tag manifest algorithm has a matching payload manifest. root is
+// only used for error reporting.
+func algoHashers(root string, algos, tagAlgos []string) ([]*hasher.Hasher, error) {
+	if len(algos) == 0 {
+		return nil, fmt.Errorf("%q has no manifest files", root)
+	}
+
+	var hashers []*hasher.Hasher
+	var seen = make(map[string]bool)
+	for _, algo := range algos {
+		var h = hasher.FromString(algo)
+		if h == nil {
+			return nil, fmt.Errorf("%q: unsupported manifest algorithm %q", root, algo)
+		}
+		hashers = append(hashers, h)
+		seen[algo] = true
+	}
+
+	for _, algo := range tagAlgos {
+		if hasher.FromString(algo) == nil {
+			return nil, fmt.Errorf("%q: unsupported tag manifest algorithm %q", root, algo)
+		}
+		if !seen[algo] {
+			return nil, fmt.Errorf("%q: tag manifest for %q has no matching payload manifest", root, algo)
+		}
+	}
+
+	return hashers, nil
+}
+
+// ManifestAlgos returns the algorithm names of the bag's payload manifests
+// and tag manifests, going by the "manifest-[algo].txt" and
+// "tagmanifest-[algo].txt" files in its root, each sorted by name. This is
+// how Open decides which hashers a bag needs. The algorithms aren't checked
+// against what the hasher package supports.
+func (b *Bag) ManifestAlgos() (manifests, tagManifests []string, err error) {
+	manifests, err = findAlgos(b.root, "manifest-")
+	if err == nil {
+		tagManifests, err = findAlgos(b.root, "tagmanifest-")
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return manifests, tagManifests, nil
+}
+
+// findAlgos returns the algorithm names from all files in root named
+// "[prefix][algo].txt", sorted by name
+func findAlgos(root, prefix string) ([]string, error) {
+	var matches, err = filepath.Glob(filepath.Join(root, prefix+"*.txt"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to search for manifests in %q: %s", root, err)
+	}
+
+	for i, m := range matches {
+		matches[i] = filepath.Base(m)
+	}
+	return algosFromNames(matches, prefix), nil
+}
+
+// algosFromNames returns the algorithm names from all file names in the list
+// matching "[prefix][algo].txt", sorted by name
+func algosFromNames(names []string, prefix string) []string {
+	var algos []string
+	for _, name := range names {
+		if strings.HasPrefix(name, prefix) && strings.HasSuffix(name, ".txt") {
+			algos = append(algos, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".txt"))
+		}
+	}
+	sort.Strings(algos)
+	return algos
+}
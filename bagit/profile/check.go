@@ -0,0 +1,154 @@
+package profile
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/uoregon-libraries/gopkg/bagit"
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// Check reads the bag's declaration, bag-info.txt, and fetch.txt, and
+// returns every way in which the bag doesn't conform to the profile. An error
+// is returned only if the bag's files can't be read.
+//
+// Check doesn't validate the bag's checksums; use bag.Validate for that. It
+// also can't know whether the bag came from a serialized archive, so
+// serialization rules are checked separately, by CheckSerialization.
+func (p *Profile) Check(b *bagit.Bag) ([]*Violation, error) {
+	var err = b.ReadDeclaration()
+	if err == nil {
+		err = b.ReadBagInfo()
+	}
+	if err == nil {
+		err = b.ReadFetchFile()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var violations = p.checkBagInfo(b)
+
+	var manifests, tagManifests []string
+	manifests, tagManifests, err = b.ManifestAlgos()
+	if err != nil {
+		return nil, err
+	}
+	violations = append(violations, checkAlgos(manifests, p.ManifestsRequired, p.ManifestsAllowed, MissingManifest, DisallowedManifest)...)
+	violations = append(violations, checkAlgos(tagManifests, p.TagManifestsRequired, p.TagManifestsAllowed, MissingTagManifest, DisallowedTagManifest)...)
+
+	for _, fname := range p.TagFilesRequired {
+		if !fileutil.IsFile(filepath.Join(b.Root(), filepath.FromSlash(fname))) {
+			violations = append(violations, &Violation{Kind: MissingTagFile, Subject: fname})
+		}
+	}
+
+	var hasFetch = fileutil.Exists(filepath.Join(b.Root(), "fetch.txt"))
+	if hasFetch && !p.allowsFetch() {
+		violations = append(violations, &Violation{Kind: FetchNotAllowed, Subject: "fetch.txt"})
+	}
+	if !hasFetch && p.FetchRequired {
+		violations = append(violations, &Violation{Kind: FetchRequired, Subject: "fetch.txt"})
+	}
+
+	if len(p.AcceptBagItVersion) > 0 && !contains(p.AcceptBagItVersion, b.Version) {
+		violations = append(violations, &Violation{Kind: UnacceptedVersion, Subject: "bagit.txt", Value: b.Version, Allowed: p.AcceptBagItVersion})
+	}
+
+	sortViolations(violations)
+	return violations, nil
+}
+
+// checkBagInfo returns violations of the profile's Bag-Info rules
+func (p *Profile) checkBagInfo(b *bagit.Bag) []*Violation {
+	var labels []string
+	for label := range p.BagInfo {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	var violations []*Violation
+	for _, label := range labels {
+		var rule = p.BagInfo[label]
+		var values = b.Info.GetAll(label)
+
+		var empty = true
+		for _, val := range values {
+			if val != "" {
+				empty = false
+			}
+		}
+		if rule.Required && empty {
+			violations = append(violations, &Violation{Kind: MissingTag, Subject: label})
+		}
+		if !rule.repeatable() && len(values) > 1 {
+			violations = append(violations, &Violation{Kind: RepeatedTag, Subject: label})
+		}
+		if len(rule.Values) == 0 {
+			continue
+		}
+		for _, val := range values {
+			if !contains(rule.Values, val) {
+				violations = append(violations, &Violation{Kind: InvalidTagValue, Subject: label, Value: val, Allowed: rule.Values})
+			}
+		}
+	}
+
+	return violations
+}
+
+// checkAlgos makes sure the algorithms in actual include everything in
+// required and nothing outside allowed (if allowed isn't empty)
+func checkAlgos(actual, required, allowed []string, missing, disallowed ViolationKind) []*Violation {
+	var violations []*Violation
+	for _, algo := range required {
+		if !contains(actual, algo) {
+			violations = append(violations, &Violation{Kind: missing, Subject: algo})
+		}
+	}
+	if len(allowed) == 0 {
+		return violations
+	}
+	for _, algo := range actual {
+		if !contains(allowed, algo) {
+			violations = append(violations, &Violation{Kind: disallowed, Subject: algo, Allowed: allowed})
+		}
+	}
+	return violations
+}
+
+// mimeTypes maps each serialization format to the MIME types a profile might
+// use for it. The spec doesn't mandate any particular type, so we accept the
+// common variants.
+var mimeTypes = map[bagit.Format][]string{
+	bagit.Tar:     {"application/tar", "application/x-tar"},
+	bagit.TarGzip: {"application/gzip", "application/x-gzip", "application/tar+gzip", "application/x-gtar"},
+	bagit.Zip:     {"application/zip", "application/x-zip-compressed"},
+}
+
+// CheckSerialization returns violations of the profile's serialization rules
+// for a serialized bag at path. The format is determined from the file's
+// extension; see bagit.FormatFromFilename. Since a profile which requires
+// serialization can't be satisfied by a bag directory, callers accepting
+// unserialized bags should check p.Serialization themselves.
+func (p *Profile) CheckSerialization(path string) ([]*Violation, error) {
+	var name = filepath.Base(path)
+	if p.Serialization == SerializationForbidden {
+		return []*Violation{{Kind: ForbiddenSerialization, Subject: name}}, nil
+	}
+
+	var f, err = bagit.FormatFromFilename(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.AcceptSerialization) == 0 {
+		return nil, nil
+	}
+	for _, mt := range mimeTypes[f] {
+		if contains(p.AcceptSerialization, mt) {
+			return nil, nil
+		}
+	}
+
+	return []*Violation{{Kind: UnacceptedSerialization, Subject: name, Value: mimeTypes[f][0], Allowed: p.AcceptSerialization}}, nil
+}
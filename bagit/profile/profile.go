@@ -0,0 +1,109 @@
+// Package profile checks bags against BagIt Profiles
+// (https://bagit-profiles.github.io/bagit-profiles-specification/), JSON
+// documents which describe what a particular institution or consortium
+// requires of the bags it accepts.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Info holds the profile's "BagIt-Profile-Info" metadata
+type Info struct {
+	Identifier          string `json:"BagIt-Profile-Identifier"`
+	Version             string `json:"Version"`
+	SourceOrganization  string `json:"Source-Organization"`
+	ContactName         string `json:"Contact-Name"`
+	ContactEmail        string `json:"Contact-Email"`
+	ExternalDescription string `json:"External-Description"`
+	ProfileVersion      string `json:"BagIt-Profile-Version"`
+}
+
+// TagRule describes the constraints on a single bag-info.txt label
+type TagRule struct {
+	Required    bool     `json:"required"`
+	Values      []string `json:"values"`     // If non-empty, the only values allowed
+	Repeatable  *bool    `json:"repeatable"` // Defaults to true if not specified
+	Description string   `json:"description"`
+}
+
+// repeatable returns the rule's repeatable setting, applying the spec's
+// default
+func (r TagRule) repeatable() bool {
+	return r.Repeatable == nil || *r.Repeatable
+}
+
+// Serialization values from the spec
+const (
+	SerializationForbidden = "forbidden"
+	SerializationRequired  = "required"
+	SerializationOptional  = "optional"
+)
+
+// Profile is a parsed BagIt Profile. Only the parts of the spec we check are
+// represented here.
+type Profile struct {
+	Info                 Info               `json:"BagIt-Profile-Info"`
+	BagInfo              map[string]TagRule `json:"Bag-Info"`
+	ManifestsRequired    []string           `json:"Manifests-Required"`
+	ManifestsAllowed     []string           `json:"Manifests-Allowed"`
+	TagManifestsRequired []string           `json:"Tag-Manifests-Required"`
+	TagManifestsAllowed  []string           `json:"Tag-Manifests-Allowed"`
+	TagFilesRequired     []string           `json:"Tag-Files-Required"`
+	AllowFetch           *bool              `json:"Allow-Fetch.txt"` // Defaults to true if not specified
+	FetchRequired        bool               `json:"Fetch.txt-Required"`
+	Serialization        string             `json:"Serialization"`
+	AcceptSerialization  []string           `json:"Accept-Serialization"`
+	AcceptBagItVersion   []string           `json:"Accept-BagIt-Version"`
+}
+
+// Parse reads a BagIt Profile JSON document from r
+func Parse(r io.Reader) (*Profile, error) {
+	var p = &Profile{}
+	var err = json.NewDecoder(r).Decode(p)
+	if err != nil {
+		return nil, fmt.Errorf("invalid profile: %w", err)
+	}
+
+	switch p.Serialization {
+	case "", SerializationForbidden, SerializationRequired, SerializationOptional:
+	default:
+		return nil, fmt.Errorf("invalid profile: unknown Serialization value %q", p.Serialization)
+	}
+
+	return p, nil
+}
+
+// Load reads a BagIt Profile JSON document from the file at path
+func Load(path string) (*Profile, error) {
+	var f, err = os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open profile %q: %w", path, err)
+	}
+	defer f.Close()
+
+	var p *Profile
+	p, err = Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load profile %q: %w", path, err)
+	}
+	return p, nil
+}
+
+// allowsFetch returns the profile's Allow-Fetch.txt setting, applying the
+// spec's default
+func (p *Profile) allowsFetch() bool {
+	return p.AllowFetch == nil || *p.AllowFetch
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package profile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/bagit"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func makeBag(t *testing.T, hashers ...*hasher.Hasher) *bagit.Bag {
+	var root = t.TempDir()
+	var err = os.Mkdir(filepath.Join(root, "data"), 0755)
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(root, "data", "file.txt"), []byte("test file\n"), 0644)
+	}
+	if err != nil {
+		t.Fatalf("Unable to create test bag: %s", err)
+	}
+	return bagit.New(root, hashers...)
+}
+
+func loadProfile(t *testing.T) *Profile {
+	var p, err = Load(filepath.Join("testdata", "profile.json"))
+	if err != nil {
+		t.Fatalf("Unable to load profile: %s", err)
+	}
+	return p
+}
+
+func violationStrings(list []*Violation) string {
+	var s []string
+	for _, v := range list {
+		s = append(s, v.String())
+	}
+	return strings.Join(s, "\n")
+}
+
+func TestLoad(t *testing.T) {
+	var p = loadProfile(t)
+	assert.Equal("https://example.org/bagit-profile.json", p.Info.Identifier, "profile identifier", t)
+	assert.True(p.BagInfo["Source-Organization"].Required, "Source-Organization is required", t)
+	assert.False(p.BagInfo["Contact-Email"].repeatable(), "Contact-Email isn't repeatable", t)
+	assert.True(p.BagInfo["External-Identifier"].repeatable(), "External-Identifier is repeatable by default", t)
+	assert.False(p.allowsFetch(), "fetch.txt isn't allowed", t)
+
+	var _, err = Parse(strings.NewReader(`{"Serialization": "sometimes"}`))
+	if err == nil {
+		t.Fatalf("An invalid Serialization value should be an error")
+	}
+}
+
+func TestCheckConforming(t *testing.T) {
+	var b = makeBag(t, hasher.NewSHA256())
+	b.Info.Set(bagit.SourceOrganization, "University of Oregon Libraries")
+	b.Info.Set(bagit.ContactEmail, "someone@example.org")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Unable to write tag files: %s", err)
+	}
+
+	var violations []*Violation
+	violations, err = loadProfile(t).Check(b)
+	if err != nil {
+		t.Fatalf("Unable to check bag: %s", err)
+	}
+	if len(violations) > 0 {
+		t.Fatalf("Bag should conform, but got violations:\n%s", violationStrings(violations))
+	}
+}
+
+func TestCheckViolations(t *testing.T) {
+	var b = makeBag(t, hasher.NewMD5())
+	b.Info.Set(bagit.SourceOrganization, "Somewhere Else")
+	b.Info.Add(bagit.ContactEmail, "one@example.org")
+	b.Info.Add(bagit.ContactEmail, "two@example.org")
+	b.AddFetchItem("file:///tmp/file.txt", -1, "data/file.txt")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Unable to write tag files: %s", err)
+	}
+
+	var violations []*Violation
+	violations, err = loadProfile(t).Check(b)
+	if err != nil {
+		t.Fatalf("Unable to check bag: %s", err)
+	}
+
+	var expected = []ViolationKind{InvalidTagValue, RepeatedTag, MissingManifest, DisallowedManifest, MissingTagManifest, FetchNotAllowed}
+	var kinds []ViolationKind
+	for _, v := range violations {
+		kinds = append(kinds, v.Kind)
+	}
+	assert.Equal(len(expected), len(kinds), "violation count:\n"+violationStrings(violations), t)
+	for i := range expected {
+		if i < len(kinds) {
+			assert.Equal(expected[i], kinds[i], "violation kind", t)
+		}
+	}
+}
+
+func TestCheckSerialization(t *testing.T) {
+	var p = loadProfile(t)
+	var violations, err = p.CheckSerialization("bag.zip")
+	assert.NilError(err, "checking zip serialization", t)
+	assert.Equal(0, len(violations), "zip violations", t)
+
+	violations, err = p.CheckSerialization("bag.tar.gz")
+	assert.NilError(err, "checking tar.gz serialization", t)
+	assert.Equal(1, len(violations), "tar.gz violations", t)
+	assert.Equal(UnacceptedSerialization, violations[0].Kind, "tar.gz violation kind", t)
+
+	p.Serialization = SerializationForbidden
+	violations, err = p.CheckSerialization("bag.zip")
+	assert.NilError(err, "checking forbidden serialization", t)
+	assert.Equal(ForbiddenSerialization, violations[0].Kind, "forbidden violation kind", t)
+}
@@ -0,0 +1,114 @@
+package profile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ViolationKind tells us which of a profile's rules a bag broke
+type ViolationKind int
+
+// All kinds of violations a profile check can report
+const (
+	MissingTag              ViolationKind = iota + 1 // A required bag-info tag is missing or empty
+	InvalidTagValue                                  // A bag-info tag has a value the profile doesn't allow
+	RepeatedTag                                      // A non-repeatable bag-info tag appears more than once
+	MissingManifest                                  // A required payload manifest is missing
+	DisallowedManifest                               // A payload manifest uses an algorithm the profile doesn't allow
+	MissingTagManifest                               // A required tag manifest is missing
+	DisallowedTagManifest                            // A tag manifest uses an algorithm the profile doesn't allow
+	MissingTagFile                                   // A required tag file is missing
+	FetchNotAllowed                                  // The bag has a fetch.txt, but the profile forbids it
+	FetchRequired                                    // The bag has no fetch.txt, but the profile requires one
+	UnacceptedVersion                                // The bag's BagIt version isn't one the profile accepts
+	ForbiddenSerialization                           // The bag is serialized, but the profile forbids it
+	UnacceptedSerialization                          // The bag is serialized in a format the profile doesn't accept
+)
+
+func (k ViolationKind) String() string {
+	switch k {
+	case MissingTag:
+		return "missing tag"
+	case InvalidTagValue:
+		return "invalid tag value"
+	case RepeatedTag:
+		return "repeated tag"
+	case MissingManifest:
+		return "missing manifest"
+	case DisallowedManifest:
+		return "disallowed manifest"
+	case MissingTagManifest:
+		return "missing tag manifest"
+	case DisallowedTagManifest:
+		return "disallowed tag manifest"
+	case MissingTagFile:
+		return "missing tag file"
+	case FetchNotAllowed:
+		return "fetch not allowed"
+	case FetchRequired:
+		return "fetch required"
+	case UnacceptedVersion:
+		return "unaccepted version"
+	case ForbiddenSerialization:
+		return "serialization forbidden"
+	case UnacceptedSerialization:
+		return "unaccepted serialization"
+	}
+	return fmt.Sprintf("unknown violation (%d)", int(k))
+}
+
+// A Violation is a single way in which a bag doesn't conform to a profile
+type Violation struct {
+	Kind    ViolationKind
+	Subject string   // What the violation is about: a bag-info label, algorithm, file name, etc.
+	Value   string   // The offending value, if any
+	Allowed []string // For violations of a list of allowed values, the list
+}
+
+// String returns a human-readable description of the violation
+func (v *Violation) String() string {
+	switch v.Kind {
+	case MissingTag:
+		return fmt.Sprintf("%s: %q is required in bag-info.txt", v.Kind, v.Subject)
+	case InvalidTagValue:
+		return fmt.Sprintf("%s: %q has value %q, must be one of %s", v.Kind, v.Subject, v.Value, quoteList(v.Allowed))
+	case RepeatedTag:
+		return fmt.Sprintf("%s: %q must not be repeated in bag-info.txt", v.Kind, v.Subject)
+	case MissingManifest, MissingTagManifest:
+		return fmt.Sprintf("%s: a %s manifest is required", v.Kind, v.Subject)
+	case DisallowedManifest, DisallowedTagManifest:
+		return fmt.Sprintf("%s: %s is not allowed, must be one of %s", v.Kind, v.Subject, quoteList(v.Allowed))
+	case MissingTagFile:
+		return fmt.Sprintf("%s: %q is required", v.Kind, v.Subject)
+	case FetchNotAllowed:
+		return fmt.Sprintf("%s: the bag must not have a fetch.txt", v.Kind)
+	case FetchRequired:
+		return fmt.Sprintf("%s: the bag must have a fetch.txt", v.Kind)
+	case UnacceptedVersion:
+		return fmt.Sprintf("%s: BagIt version %q is not accepted, must be one of %s", v.Kind, v.Value, quoteList(v.Allowed))
+	case ForbiddenSerialization:
+		return fmt.Sprintf("%s: %q must not be serialized", v.Kind, v.Subject)
+	case UnacceptedSerialization:
+		return fmt.Sprintf("%s: %q is serialized as %s, must be one of %s", v.Kind, v.Subject, v.Value, quoteList(v.Allowed))
+	}
+	return fmt.Sprintf("%s: %q", v.Kind, v.Subject)
+}
+
+func quoteList(list []string) string {
+	var quoted = make([]string, len(list))
+	for i, s := range list {
+		quoted[i] = fmt.Sprintf("%q", s)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// sortViolations sorts by kind, then subject, so reports are predictable
+func sortViolations(list []*Violation) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Kind != list[j].Kind {
+			return list[i].Kind < list[j].Kind
+		}
+		return list[i].Subject < list[j].Subject
+	})
+}
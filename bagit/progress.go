@@ -0,0 +1,31 @@
+package bagit
+
+import (
+	"context"
+	"io"
+)
+
+// Progress describes how far along payload hashing is. It's passed to a bag's
+// OnProgress function after each file is hashed.
+type Progress struct {
+	Files      int64  // Number of files hashed so far
+	TotalFiles int64  // Number of files in the payload
+	Bytes      int64  // Number of bytes hashed so far
+	TotalBytes int64  // Size of the payload in bytes
+	Path       string // Bag-relative path of the file which was just hashed
+}
+
+// ctxReader wraps an io.Reader, failing reads once its context is canceled
+// so a huge file doesn't hold up cancellation
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r *ctxReader) Read(p []byte) (int, error) {
+	var err = r.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,61 @@
+package bagit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/fileutil"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestProgress(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+	b.Workers = 2
+
+	var reports []Progress
+	b.OnProgress = func(p Progress) {
+		reports = append(reports, p)
+	}
+	var err = b.WriteTagFilesContext(context.Background())
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	assert.Equal(2, len(reports), "progress report count", t)
+	var last = reports[len(reports)-1]
+	assert.Equal(int64(2), last.Files, "files done", t)
+	assert.Equal(int64(2), last.TotalFiles, "total files", t)
+	assert.Equal(int64(23), last.Bytes, "bytes done", t)
+	assert.Equal(int64(23), last.TotalBytes, "total bytes", t)
+}
+
+func TestCancel(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	b.OnProgress = func(p Progress) {
+		cancel()
+	}
+	var err = b.WriteTagFilesContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a context.Canceled error, got %v", err)
+	}
+	if fileutil.Exists(filepath.Join(root, "manifest-sha256.txt")) {
+		t.Fatalf("A canceled WriteTagFilesContext shouldn't write a manifest")
+	}
+
+	b.OnProgress = nil
+	err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+	_, err = b.ValidateContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected validation to return a context.Canceled error, got %v", err)
+	}
+}
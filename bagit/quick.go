@@ -0,0 +1,93 @@
+package bagit
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ValidateQuick does a fast sanity check of the bag without reading any
+// payload data: it only checks file lists and sizes, so it's useful for
+// confirming a transfer didn't lose or truncate anything when a full
+// Validate would take hours.
+//
+// Every payload manifest's file list is compared against the files actually
+// in data/, and if bag-info.txt has a Payload-Oxum, its byte and file counts
+// are compared against the payload on disk. An invalid Payload-Oxum is
+// reported as an OxumMismatch rather than an error, as it's a problem with
+// the bag, not the validation process.
+//
+// Errors are returned for the same reasons as Validate.
+func (b *Bag) ValidateQuick() (discrepancies []*Discrepancy, err error) {
+	discrepancies, err = b.readManifests()
+	if err == nil {
+		err = b.ReadBagInfo()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(discrepancies) > 0 {
+		return discrepancies, nil
+	}
+
+	var actual = make(map[string]bool)
+	var octets int64
+	err = b.walkPayload(func(path string, info os.FileInfo) error {
+		var relPath, err = filepath.Rel(b.root, path)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
+		}
+		actual[relPath] = true
+		octets += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, h := range b.Hashers {
+		var label = b.manifestLabel("manifest", h)
+		var listed = make(map[string]bool)
+		var list []*Discrepancy
+		for _, ck := range b.ManifestChecksums[h.Name] {
+			listed[ck.Path] = true
+			if !actual[ck.Path] {
+				list = append(list, missingFile(label, ck.Path, ck.Checksum))
+			}
+		}
+		for path := range actual {
+			if !listed[path] {
+				list = append(list, extraFile(label, path, ""))
+			}
+		}
+		sortDiscrepancies(list)
+		discrepancies = append(discrepancies, list...)
+	}
+
+	var d = b.checkOxum(octets, int64(len(actual)))
+	if d != nil {
+		discrepancies = append(discrepancies, d)
+	}
+	return discrepancies, nil
+}
+
+// checkOxum returns an OxumMismatch discrepancy if bag-info.txt has a
+// Payload-Oxum which doesn't match the given payload byte and file counts
+func (b *Bag) checkOxum(octets, count int64) *Discrepancy {
+	var oxum = b.Info.Get(PayloadOxum)
+	if oxum == "" {
+		return nil
+	}
+
+	var expectedOctets, expectedCount, err = b.Info.PayloadOxum()
+	if err == nil && expectedOctets == octets && expectedCount == count {
+		return nil
+	}
+	return &Discrepancy{
+		Kind:         OxumMismatch,
+		Path:         filepath.Base(b.bagInfoFilename()),
+		ManifestType: PayloadOxum,
+		Expected:     oxum,
+		Actual:       fmt.Sprintf("%d.%d", octets, count),
+	}
+}
@@ -0,0 +1,66 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestValidateQuick(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256())
+	b.Info.Set(SourceOrganization, "UO")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateQuick()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Validation failed: %s", joinDiscrepancies(discrepancies))
+	}
+
+	// Same-size corruption can't be caught by a quick validation
+	var fname = filepath.Join(root, "data", "test.txt")
+	err = ioutil.WriteFile(fname, []byte("corrupted!"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateQuick()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(0, len(discrepancies), "discrepancies after same-size corruption", t)
+
+	// But a truncation should be caught by the oxum
+	err = ioutil.WriteFile(fname, []byte("short"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write %q: %s", fname, err)
+	}
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateQuick()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "discrepancies after truncation", t)
+	assert.Equal(OxumMismatch, discrepancies[0].Kind, "oxum mismatch reported", t)
+	assert.Equal("23.2", discrepancies[0].Expected, "expected oxum", t)
+	assert.Equal("18.2", discrepancies[0].Actual, "actual oxum", t)
+
+	// Removing a file should be caught by the manifest as well as the oxum
+	os.Remove(fname)
+	discrepancies, err = New(root, hasher.NewSHA256()).ValidateQuick()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(2, len(discrepancies), "discrepancies after removal", t)
+	assert.Equal(Missing, discrepancies[0].Kind, "missing file reported", t)
+	assert.Equal("data/test.txt", discrepancies[0].Path, "missing file path", t)
+}
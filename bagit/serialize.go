@@ -0,0 +1,296 @@
+package bagit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// Format is an archive format a bag can be serialized to
+type Format int
+
+// All serialization formats we support
+const (
+	Tar Format = iota + 1
+	TarGzip
+	Zip
+)
+
+func (f Format) String() string {
+	switch f {
+	case Tar:
+		return "tar"
+	case TarGzip:
+		return "tar.gz"
+	case Zip:
+		return "zip"
+	}
+	return fmt.Sprintf("unknown format (%d)", int(f))
+}
+
+// FormatFromFilename returns the serialization format implied by a file's
+// extension: ".tar", ".tar.gz" (or ".tgz"), or ".zip"
+func FormatFromFilename(name string) (Format, error) {
+	var lower = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar"):
+		return Tar, nil
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return TarGzip, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return Zip, nil
+	}
+	return 0, fmt.Errorf("unable to determine serialization format for %q", name)
+}
+
+// archiveWriter abstracts the differences between tar and zip writers
+type archiveWriter interface {
+	writeDir(name string, info os.FileInfo) error
+	writeFile(name string, info os.FileInfo, r io.Reader) error
+	Close() error
+}
+
+type tarWriter struct {
+	tw *tar.Writer
+	gz *gzip.Writer
+}
+
+func newTarWriter(w io.Writer, compress bool) *tarWriter {
+	var t = &tarWriter{}
+	if compress {
+		t.gz = gzip.NewWriter(w)
+		w = t.gz
+	}
+	t.tw = tar.NewWriter(w)
+	return t
+}
+
+func (t *tarWriter) writeHeader(name string, info os.FileInfo) error {
+	var hdr, err = tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	return t.tw.WriteHeader(hdr)
+}
+
+func (t *tarWriter) writeDir(name string, info os.FileInfo) error {
+	return t.writeHeader(name+"/", info)
+}
+
+func (t *tarWriter) writeFile(name string, info os.FileInfo, r io.Reader) error {
+	var err = t.writeHeader(name, info)
+	if err == nil {
+		_, err = io.Copy(t.tw, r)
+	}
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	var err = t.tw.Close()
+	if t.gz != nil {
+		var gzErr = t.gz.Close()
+		if err == nil {
+			err = gzErr
+		}
+	}
+	return err
+}
+
+type zipWriter struct {
+	zw *zip.Writer
+}
+
+func (z *zipWriter) writeDir(name string, info os.FileInfo) error {
+	var hdr, err = zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name + "/"
+	_, err = z.zw.CreateHeader(hdr)
+	return err
+}
+
+func (z *zipWriter) writeFile(name string, info os.FileInfo, r io.Reader) error {
+	var hdr, err = zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	hdr.Name = name
+	hdr.Method = zip.Deflate
+
+	var w io.Writer
+	w, err = z.zw.CreateHeader(hdr)
+	if err == nil {
+		_, err = io.Copy(w, r)
+	}
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.zw.Close()
+}
+
+// serialEntry is a file or directory to be written to an archive
+type serialEntry struct {
+	relPath string
+	info    os.FileInfo
+}
+
+// serialEntries returns everything under root in the order it should be
+// archived: top-level tag files first, starting with bagit.txt, and then all
+// directories and files in the order filepath.Walk visits them. Putting tag
+// files first means readers can know a bag's manifests before they see any
+// payload data.
+func serialEntries(root string) ([]serialEntry, error) {
+	var tagFiles, rest []serialEntry
+	var err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == root {
+			return nil
+		}
+
+		var relPath, _ = filepath.Rel(root, path)
+		var entry = serialEntry{relPath: filepath.ToSlash(relPath), info: info}
+		switch {
+		case info.Mode().IsRegular() && filepath.Dir(relPath) == ".":
+			tagFiles = append(tagFiles, entry)
+		case info.Mode().IsRegular() || info.IsDir():
+			rest = append(rest, entry)
+		default:
+			return fmt.Errorf("unable to serialize special file %q", path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(tagFiles, func(i, j int) bool {
+		if tagFiles[i].relPath == "bagit.txt" || tagFiles[j].relPath == "bagit.txt" {
+			return tagFiles[i].relPath == "bagit.txt"
+		}
+		return tagFiles[i].relPath < tagFiles[j].relPath
+	})
+	return append(tagFiles, rest...), nil
+}
+
+// Serialize writes the entire bag to w as an archive in the given format.
+// Per the spec, everything is put under a single top-level directory named
+// after the bag's root directory. The bag's tag files must already have been
+// written; Serialize doesn't generate or validate anything.
+//
+// Symlinks and other special files are not allowed in a serialized bag, and
+// cause an error.
+func (b *Bag) Serialize(w io.Writer, f Format) error {
+	var root, err = filepath.Abs(b.root)
+	if err != nil {
+		return fmt.Errorf("unable to determine bag's absolute root path from %q: %s", b.root, err)
+	}
+	if !fileutil.IsDir(filepath.Join(root, "data")) {
+		return fmt.Errorf(`%q is not a bag: missing or invalid "data" directory`, b.root)
+	}
+
+	var aw archiveWriter
+	switch f {
+	case Tar, TarGzip:
+		aw = newTarWriter(w, f == TarGzip)
+	case Zip:
+		aw = &zipWriter{zw: zip.NewWriter(w)}
+	default:
+		return fmt.Errorf("invalid serialization format %s", f)
+	}
+
+	var entries []serialEntry
+	entries, err = serialEntries(root)
+	if err != nil {
+		return err
+	}
+
+	var top = filepath.Base(root)
+	for _, entry := range entries {
+		var name = top + "/" + entry.relPath
+		if entry.info.IsDir() {
+			err = aw.writeDir(name, entry.info)
+		} else {
+			err = writeArchiveFile(aw, name, entry.info, filepath.Join(root, filepath.FromSlash(entry.relPath)))
+		}
+		if err != nil {
+			return fmt.Errorf("unable to serialize %q: %s", entry.relPath, err)
+		}
+	}
+
+	return aw.Close()
+}
+
+func writeArchiveFile(aw archiveWriter, name string, info os.FileInfo, path string) error {
+	var f, err = os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return aw.writeFile(name, info, f)
+}
+
+// SerializeFile writes the bag to an archive at path, using the format
+// implied by its extension; see FormatFromFilename. path must not be inside
+// the bag. The archive is written to a temp file next to path and only
+// renamed into place once it's complete, so if serialization fails, any file
+// already at path is left alone.
+func (b *Bag) SerializeFile(path string) error {
+	var f, err = FormatFromFilename(path)
+	if err != nil {
+		return err
+	}
+
+	var root, dest string
+	root, err = filepath.Abs(b.root)
+	if err == nil {
+		dest, err = filepath.Abs(path)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to determine absolute paths for %q and %q: %s", b.root, path, err)
+	}
+	if strings.HasPrefix(dest, root+string(filepath.Separator)) {
+		return fmt.Errorf("cannot serialize bag %q into itself", b.root)
+	}
+
+	var tmp *os.File
+	tmp, err = fileutil.TempFile(filepath.Dir(dest), "."+filepath.Base(dest)+"-", "")
+	if err != nil {
+		return fmt.Errorf("unable to create temp file for %q: %s", path, err)
+	}
+	var tmpName = tmp.Name()
+
+	err = b.Serialize(tmp, f)
+	if err == nil {
+		// TempFile is owner-only, but the archive should be readable like any
+		// other file
+		err = tmp.Chmod(0644)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	var cerr = tmp.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmpName, dest)
+	}
+	if err != nil {
+		os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,281 @@
+package bagit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestSerialize(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256(), hasher.NewMD5())
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	for _, name := range []string{"bag.tar", "bag.tar.gz", "bag.zip"} {
+		var fname = filepath.Join(t.TempDir(), name)
+		err = b.SerializeFile(fname)
+		if err != nil {
+			t.Fatalf("Unable to serialize %s: %s", name, err)
+		}
+
+		var discrepancies []*Discrepancy
+		discrepancies, err = ValidateSerialized(fname)
+		if err != nil {
+			t.Fatalf("Unable to validate %s: %s", name, err)
+		}
+		if len(discrepancies) > 0 {
+			t.Fatalf("%s is invalid: %s", name, joinDiscrepancies(discrepancies))
+		}
+	}
+
+	err = b.SerializeFile(filepath.Join(root, "bag.tar"))
+	if err == nil {
+		t.Fatalf("Serializing a bag into itself should be an error")
+	}
+}
+
+func TestSerializeFileFailureKeepsArchive(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewMD5())
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	var dir = t.TempDir()
+	var fname = filepath.Join(dir, "bag.tar")
+	err = b.SerializeFile(fname)
+	if err != nil {
+		t.Fatalf("Unable to serialize bag: %s", err)
+	}
+
+	err = os.Symlink(filepath.Join(root, "data", "test.txt"), filepath.Join(root, "data", "link.txt"))
+	if err != nil {
+		t.Fatalf("Unable to create symlink: %s", err)
+	}
+	err = b.SerializeFile(fname)
+	if err == nil {
+		t.Fatalf("Serializing a bag with a symlink should be an error")
+	}
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = ValidateSerialized(fname)
+	if err != nil {
+		t.Fatalf("Unable to validate the original archive: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Original archive is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+
+	var files, _ = ioutil.ReadDir(dir)
+	assert.Equal(1, len(files), "files in the archive's directory", t)
+}
+
+// tarEntry is a file to put in a hand-built tar for testing
+type tarEntry struct {
+	name string
+	data string
+}
+
+func writeTestTar(t *testing.T, entries []tarEntry) string {
+	var buf bytes.Buffer
+	var tw = tar.NewWriter(&buf)
+	for _, e := range entries {
+		var err = tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: tar.TypeReg})
+		if err == nil {
+			_, err = tw.Write([]byte(e.data))
+		}
+		if err != nil {
+			t.Fatalf("Unable to write test tar: %s", err)
+		}
+	}
+	tw.Close()
+
+	var fname = filepath.Join(t.TempDir(), "bag.tar")
+	var err = ioutil.WriteFile(fname, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write test tar: %s", err)
+	}
+	return fname
+}
+
+func TestValidateSerializedPayloadFirst(t *testing.T) {
+	var fname = writeTestTar(t, []tarEntry{
+		{"bag/data/another.txt", "another test\n"},
+		{"bag/data/test.txt", "corrupt\n"},
+		{"bag/bagit.txt", "BagIt-Version: 0.97\nTag-File-Character-Encoding: UTF-8\n"},
+		{"bag/manifest-md5.txt", "7d980e6be82dd33893f39d652d8a99a1  data/another.txt\n" +
+			"b05403212c66bdc8ccc597fedf6cd5fe  data/test.txt\n"},
+	})
+
+	var discrepancies, err = ValidateSerialized(fname)
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "discrepancy count", t)
+	assert.Equal(Corrupt, discrepancies[0].Kind, "discrepancy kind", t)
+	assert.Equal("data/test.txt", discrepancies[0].Path, "discrepancy path", t)
+
+	fname = writeTestTar(t, []tarEntry{
+		{"bag/bagit.txt", "BagIt-Version: 0.97\nTag-File-Character-Encoding: UTF-8\n"},
+		{"other/data/test.txt", "test file\n"},
+	})
+	_, err = ValidateSerialized(fname)
+	if err == nil {
+		t.Fatalf("Multiple top-level directories should be an error")
+	}
+}
+
+// payloadFirstEntries is a bag with its payload ahead of its tag files, as
+// most tools write them, and one corrupt payload file
+var payloadFirstEntries = []tarEntry{
+	{"bag/data/another.txt", "another test\n"},
+	{"bag/data/test.txt", "corrupt\n"},
+	{"bag/bagit.txt", "BagIt-Version: 0.97\nTag-File-Character-Encoding: UTF-8\n"},
+	{"bag/manifest-md5.txt", "7d980e6be82dd33893f39d652d8a99a1  data/another.txt\n" +
+		"b05403212c66bdc8ccc597fedf6cd5fe  data/test.txt\n"},
+}
+
+func hashedNames(s *serialReader) string {
+	var names []string
+	for _, h := range s.hashed {
+		names = append(names, h.Name)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestValidateSerializedZipPayloadFirst(t *testing.T) {
+	var buf bytes.Buffer
+	var zw = zip.NewWriter(&buf)
+	for _, e := range payloadFirstEntries {
+		var w, err = zw.Create(e.name)
+		if err == nil {
+			_, err = w.Write([]byte(e.data))
+		}
+		if err != nil {
+			t.Fatalf("Unable to write test zip: %s", err)
+		}
+	}
+	zw.Close()
+
+	var fname = filepath.Join(t.TempDir(), "bag.zip")
+	var err = ioutil.WriteFile(fname, buf.Bytes(), 0644)
+	if err != nil {
+		t.Fatalf("Unable to write test zip: %s", err)
+	}
+
+	// The payload should only be hashed with the manifest's algorithm
+	var s *serialReader
+	s, err = readZip(context.Background(), fname, nil)
+	if err != nil {
+		t.Fatalf("Unable to read zip: %s", err)
+	}
+	assert.Equal("md5", hashedNames(s), "payload hashers", t)
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = ValidateSerialized(fname)
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "discrepancy count", t)
+	assert.Equal("data/test.txt", discrepancies[0].Path, "discrepancy path", t)
+}
+
+func TestValidateSerializedTarPayloadFirst(t *testing.T) {
+	var fname = writeTestTar(t, payloadFirstEntries)
+
+	// The payload is skipped on the first read, then hashed with only the
+	// manifest's algorithm on the second
+	var s, err = readTarFile(context.Background(), fname, Tar, nil)
+	if err != nil {
+		t.Fatalf("Unable to read tar: %s", err)
+	}
+	assert.Equal("md5", hashedNames(s), "payload hashers", t)
+	assert.Equal(2, len(s.payload), "hashed payload files", t)
+
+	// A stream can't be read twice, so the payload is hashed with the
+	// fallback algorithms
+	var f *os.File
+	f, err = os.Open(fname)
+	if err != nil {
+		t.Fatalf("Unable to open %q: %s", fname, err)
+	}
+	defer f.Close()
+	var discrepancies []*Discrepancy
+	discrepancies, err = ValidateSerializedReader(context.Background(), f, Tar)
+	if err != nil {
+		t.Fatalf("Unable to validate stream: %s", err)
+	}
+	assert.Equal(1, len(discrepancies), "discrepancy count", t)
+	assert.Equal("data/test.txt", discrepancies[0].Path, "discrepancy path", t)
+
+	// Algorithms outside the fallback list have to be given explicitly
+	var entries = []tarEntry{
+		{"bag/data/test.txt", "test file\n"},
+		{"bag/bagit.txt", "BagIt-Version: 0.97\nTag-File-Character-Encoding: UTF-8\n"},
+		{"bag/manifest-sha3-256.txt", hasher.New(hasher.SHA3_256).Sum(strings.NewReader("test file\n")) + "  data/test.txt\n"},
+	}
+	fname = writeTestTar(t, entries)
+	f, err = os.Open(fname)
+	if err != nil {
+		t.Fatalf("Unable to open %q: %s", fname, err)
+	}
+	defer f.Close()
+	_, err = ValidateSerializedReader(context.Background(), f, Tar)
+	if err == nil {
+		t.Fatalf("Streaming a sha3-256 bag's payload before its manifest should be an error")
+	}
+	f.Seek(0, io.SeekStart)
+	discrepancies, err = ValidateSerializedReader(context.Background(), f, Tar, hasher.New(hasher.SHA3_256))
+	if err != nil {
+		t.Fatalf("Unable to validate stream with explicit hashers: %s", err)
+	}
+	assert.Equal(0, len(discrepancies), "discrepancy count", t)
+
+	discrepancies, err = ValidateSerialized(fname)
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(0, len(discrepancies), "discrepancy count", t)
+}
+
+func TestValidateSerializedTarManifestsAroundPayload(t *testing.T) {
+	var sum = func(algo hasher.Algo) string {
+		return hasher.New(algo).Sum(strings.NewReader("test file\n"))
+	}
+	var entries = []tarEntry{
+		{"bag/bagit.txt", "BagIt-Version: 0.97\nTag-File-Character-Encoding: UTF-8\n"},
+		{"bag/manifest-md5.txt", sum(hasher.MD5) + "  data/test.txt\n"},
+		{"bag/data/test.txt", "test file\n"},
+		{"bag/manifest-sha256.txt", sum(hasher.SHA256) + "  data/test.txt\n"},
+	}
+	var fname = writeTestTar(t, entries)
+
+	// The first read only hashes the payload with md5, so the archive has to
+	// be read again to get sha256 as well
+	var s, err = readTarFile(context.Background(), fname, Tar, nil)
+	if err != nil {
+		t.Fatalf("Unable to read tar: %s", err)
+	}
+	assert.Equal("md5,sha256", hashedNames(s), "payload hashers", t)
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = ValidateSerialized(fname)
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	assert.Equal(0, len(discrepancies), "discrepancy count", t)
+}
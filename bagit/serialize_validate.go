@@ -0,0 +1,438 @@
+package bagit
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// serialReader holds the state for streaming through a serialized bag. Tag
+// files are kept in memory since they're needed to validate anything, but
+// payload entries are only hashed.
+type serialReader struct {
+	ctx      context.Context
+	hashers  []*hasher.Hasher // Hashers the caller asked for, if any
+	top      string
+	tagFiles map[string][]byte
+	hashed   []*hasher.Hasher // Hashers the payload was actually hashed with
+	payload  map[string]map[string]string
+
+	// If canDefer is true, a payload which comes before any manifest is
+	// skipped rather than hashed with fallbackAlgos, and deferred is set so
+	// the caller knows to read the archive again
+	canDefer bool
+	deferred bool
+}
+
+// fallbackAlgos are the algorithms used to hash a streamed payload which
+// comes before any manifest when the caller didn't specify any hashers.
+// These are the algorithms the BagIt spec names, which covers nearly every
+// bag in the wild without hashing everything with slower, rarer algorithms.
+var fallbackAlgos = []hasher.Algo{hasher.MD5, hasher.SHA1, hasher.SHA256, hasher.SHA512}
+
+func newSerialReader(ctx context.Context, hashers []*hasher.Hasher) *serialReader {
+	return &serialReader{
+		ctx:      ctx,
+		hashers:  hashers,
+		tagFiles: make(map[string][]byte),
+		payload:  make(map[string]map[string]string),
+	}
+}
+
+// relPath returns the path of an archive entry relative to the bag's root,
+// making sure everything is under a single top-level directory
+func (s *serialReader) relPath(name string) (string, error) {
+	var p = path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(p) || p == "." || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("invalid archive entry %q", name)
+	}
+
+	var top, rest = p, ""
+	var i = strings.Index(p, "/")
+	if i >= 0 {
+		top, rest = p[:i], p[i+1:]
+	}
+	if s.top == "" {
+		s.top = top
+	}
+	if top != s.top {
+		return "", fmt.Errorf("archive entry %q is outside the top-level directory %q", name, s.top)
+	}
+	return rest, nil
+}
+
+// add processes a single archive entry
+func (s *serialReader) add(name string, info os.FileInfo, r io.Reader) error {
+	var err = s.ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	var relPath string
+	relPath, err = s.relPath(name)
+	if err != nil {
+		return err
+	}
+
+	if info.IsDir() {
+		return nil
+	}
+	if relPath == "" {
+		return fmt.Errorf("archive must contain a single top-level directory, but %q is a file", name)
+	}
+	// Like GenerateChecksums, we only care about regular files
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+
+	if !strings.Contains(relPath, "/") {
+		s.tagFiles[relPath], err = ioutil.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("unable to read %q: %s", name, err)
+		}
+		return nil
+	}
+
+	if !strings.HasPrefix(relPath, "data/") {
+		return nil
+	}
+
+	var hashers = s.payloadHashers()
+	if hashers == nil {
+		s.deferred = true
+		return nil
+	}
+	var sums map[string]string
+	_, sums, err = copyAndHash(ioutil.Discard, &ctxReader{ctx: s.ctx, r: r}, hashers)
+	if err != nil {
+		return fmt.Errorf("unable to read %q: %w", name, err)
+	}
+	s.payload[relPath] = sums
+	return nil
+}
+
+// payloadHashers decides which algorithms to hash payload entries with the
+// first time it's called. If the caller didn't say, we use whatever manifests
+// we've seen so far. If there are none (the payload is first in the archive),
+// we return nil if hashing can be deferred, and fallbackAlgos otherwise.
+func (s *serialReader) payloadHashers() []*hasher.Hasher {
+	if s.hashed != nil || s.deferred {
+		return s.hashed
+	}
+
+	if len(s.hashers) > 0 {
+		s.hashed = s.hashers
+		return s.hashed
+	}
+
+	for _, algo := range algosFromNames(s.tagFileNames(), "manifest-") {
+		var h = hasher.FromString(algo)
+		if h != nil {
+			s.hashed = append(s.hashed, h)
+		}
+	}
+	if len(s.hashed) == 0 && !s.canDefer {
+		for _, algo := range fallbackAlgos {
+			s.hashed = append(s.hashed, hasher.New(algo))
+		}
+	}
+	return s.hashed
+}
+
+// manifestHashers returns the hashers the caller asked for, or if there
+// weren't any, a hasher for each payload manifest in the archive
+func (s *serialReader) manifestHashers() ([]*hasher.Hasher, error) {
+	if len(s.hashers) > 0 {
+		return s.hashers, nil
+	}
+	var names = s.tagFileNames()
+	return algoHashers(s.top, algosFromNames(names, "manifest-"), algosFromNames(names, "tagmanifest-"))
+}
+
+// hashedWith returns true if the payload was hashed with every one of the
+// given hashers' algorithms
+func (s *serialReader) hashedWith(hashers []*hasher.Hasher) bool {
+	var hashed = make(map[string]bool)
+	for _, h := range s.hashed {
+		hashed[h.Name] = true
+	}
+	for _, h := range hashers {
+		if !hashed[h.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *serialReader) tagFileNames() []string {
+	var names []string
+	for name := range s.tagFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readFile mimics ioutil.ReadFile for the tag files we've stored
+func (s *serialReader) readFile(fname string) ([]byte, error) {
+	var data, ok = s.tagFiles[filepath.Base(fname)]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: fname, Err: os.ErrNotExist}
+	}
+	return data, nil
+}
+
+// validate does the same work as Bag.Validate once the whole archive has
+// been read
+func (s *serialReader) validate() ([]*Discrepancy, error) {
+	if s.top == "" {
+		return nil, fmt.Errorf("archive is empty")
+	}
+
+	var hashers, err = s.manifestHashers()
+	if err != nil {
+		return nil, err
+	}
+
+	var b = New(s.top, hashers...)
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.readManifestsFrom(s.readFile)
+	if err == nil {
+		err = b.readBagInfoFrom(s.readFile)
+	}
+	if err == nil {
+		err = b.checkManifestData()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(discrepancies) > 0 {
+		return discrepancies, nil
+	}
+
+	// If the payload was hashed, it has to have been hashed with all the
+	// algorithms we need. A streamed archive can't be read again, so if some
+	// manifests came before the payload and others came after, we're stuck.
+	for _, h := range b.Hashers {
+		if len(s.payload) > 0 && !s.hashedWith([]*hasher.Hasher{h}) {
+			return nil, fmt.Errorf("unable to validate %s manifest: payload was read before the manifest", h.Name)
+		}
+	}
+
+	if len(b.ManifestTagSums) > 0 {
+		b.ActualTagSums = make(map[string][]*FileChecksum)
+		for _, name := range s.tagFileNames() {
+			var match, _ = filepath.Match("tagmanifest-*.txt", name)
+			if match {
+				continue
+			}
+			var _, sums, err = copyAndHash(ioutil.Discard, bytes.NewReader(s.tagFiles[name]), b.Hashers)
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range b.Hashers {
+				b.ActualTagSums[h.Name] = append(b.ActualTagSums[h.Name], &FileChecksum{Path: name, Checksum: sums[h.Name]})
+			}
+		}
+
+		discrepancies = b.compareTagSums()
+		if len(discrepancies) > 0 {
+			return discrepancies, nil
+		}
+	}
+
+	b.ActualChecksums = make(map[string][]*FileChecksum)
+	for p, sums := range s.payload {
+		for _, h := range b.Hashers {
+			b.ActualChecksums[h.Name] = append(b.ActualChecksums[h.Name], &FileChecksum{Path: p, Checksum: sums[h.Name]})
+		}
+	}
+	for _, sums := range b.ActualChecksums {
+		sortSums(sums)
+	}
+
+	return b.compareChecksums(), nil
+}
+
+// ValidateSerialized validates the serialized bag at path without extracting
+// it: payload files are hashed as they're read from the archive. The format
+// is determined by the file's extension; see FormatFromFilename. Apart from
+// that, this works just like Validate.
+//
+// If no hashers are given, we use whichever manifests the archive has. Zip
+// archives are read tag files first, so this costs nothing extra. Tar
+// archives have to be read in order, though, and if the payload comes before
+// any of the manifests (as it does with most tools, since "data" sorts
+// before "manifest-*.txt"), the archive has to be read a second time to hash
+// the payload once we know which algorithms it needs. Passing in the hashers
+// you need avoids this. Archives written by Serialize always put the tag
+// files first.
+func ValidateSerialized(path string, hashers ...*hasher.Hasher) ([]*Discrepancy, error) {
+	return ValidateSerializedContext(context.Background(), path, hashers...)
+}
+
+// ValidateSerializedContext is ValidateSerialized with support for
+// cancellation
+func ValidateSerializedContext(ctx context.Context, path string, hashers ...*hasher.Hasher) ([]*Discrepancy, error) {
+	var f, err = FormatFromFilename(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var s *serialReader
+	if f == Zip {
+		s, err = readZip(ctx, path, hashers)
+	} else {
+		s, err = readTarFile(ctx, path, f, hashers)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.validate()
+}
+
+// readTarFile reads the tar or tar.gz archive at path. If the payload had to
+// be skipped because it came before the manifests, or was hashed before some
+// of the manifests were seen, the archive is read a second time with the
+// manifests' hashers.
+func readTarFile(ctx context.Context, path string, f Format, hashers []*hasher.Hasher) (*serialReader, error) {
+	var s = newSerialReader(ctx, hashers)
+	s.canDefer = true
+	var err = readTarPath(s, path, f)
+	if err != nil || len(hashers) > 0 {
+		return s, err
+	}
+
+	hashers, err = s.manifestHashers()
+	if err != nil {
+		return nil, err
+	}
+	if !s.deferred && (len(s.payload) == 0 || s.hashedWith(hashers)) {
+		return s, nil
+	}
+	s = newSerialReader(ctx, hashers)
+	return s, readTarPath(s, path, f)
+}
+
+func readTarPath(s *serialReader, path string, f Format) error {
+	var file, err = os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open serialized bag %q: %s", path, err)
+	}
+	defer file.Close()
+
+	err = readTar(s, file, f)
+	if err != nil {
+		return fmt.Errorf("unable to read serialized bag %q: %w", path, err)
+	}
+	return nil
+}
+
+// readZip reads the zip archive at path. Zip archives can be read in any
+// order, so we read the tag files first: then the payload is only hashed
+// with the algorithms its manifests use, no matter where the manifests are
+// in the archive.
+func readZip(ctx context.Context, path string, hashers []*hasher.Hasher) (*serialReader, error) {
+	var zr, err = zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open serialized bag %q: %s", path, err)
+	}
+	defer zr.Close()
+
+	var files []*zip.File
+	for _, zf := range zr.File {
+		if isTopLevelFile(zf) {
+			files = append(files, zf)
+		}
+	}
+	for _, zf := range zr.File {
+		if !isTopLevelFile(zf) {
+			files = append(files, zf)
+		}
+	}
+
+	var s = newSerialReader(ctx, hashers)
+	for _, zf := range files {
+		err = addZipFile(s, zf)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read serialized bag %q: %w", path, err)
+		}
+	}
+	return s, nil
+}
+
+// isTopLevelFile returns true if zf is a file directly inside the archive's
+// top-level directory, such as a manifest or other tag file
+func isTopLevelFile(zf *zip.File) bool {
+	var p = path.Clean(strings.TrimPrefix(zf.Name, "./"))
+	return strings.Count(p, "/") == 1 && !zf.FileInfo().IsDir()
+}
+
+func addZipFile(s *serialReader, zf *zip.File) error {
+	var r, err = zf.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return s.add(zf.Name, zf.FileInfo(), r)
+}
+
+// ValidateSerializedReader validates a tar or tar.gz serialized bag read
+// from r, such as a network stream. Zip archives can't be streamed, so they
+// can only be validated with ValidateSerialized. See ValidateSerialized for
+// details.
+//
+// A stream can't be read twice, so if no hashers are given and the payload
+// comes before the manifests, the payload is hashed with md5, sha1, sha256,
+// and sha512, the algorithms the BagIt spec names. A bag using any other
+// algorithm can only be validated this way if its hashers are given.
+func ValidateSerializedReader(ctx context.Context, r io.Reader, f Format, hashers ...*hasher.Hasher) ([]*Discrepancy, error) {
+	var s = newSerialReader(ctx, hashers)
+	var err = readTar(s, r, f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read serialized bag: %w", err)
+	}
+	return s.validate()
+}
+
+// readTar passes every entry in the tar or tar.gz archive in r to s
+func readTar(s *serialReader, r io.Reader, f Format) error {
+	switch f {
+	case Tar:
+	case TarGzip:
+		var gz, err = gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	default:
+		return fmt.Errorf("unable to stream a %s serialized bag", f)
+	}
+
+	var tr = tar.NewReader(r)
+	for {
+		var hdr, err = tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err == nil {
+			err = s.add(hdr.Name, hdr.FileInfo(), tr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package bagit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// SpecialFilePolicy tells a bag what to do with payload entries which aren't
+// regular files or directories: symlinks, FIFOs, devices, and sockets
+type SpecialFilePolicy int
+
+// All special file policies
+const (
+	// SkipSpecialFiles leaves special files out of the manifests, recording
+	// each in Bag.Skipped. This is the default.
+	SkipSpecialFiles SpecialFilePolicy = iota
+
+	// FollowSymlinks treats each symlink as the file or directory it points
+	// to. Symlinks which are broken or would cause a loop, and all other
+	// special files, are skipped as with SkipSpecialFiles.
+	FollowSymlinks
+
+	// FailOnSpecialFiles makes any special file in the payload an error
+	FailOnSpecialFiles
+)
+
+// SkippedFile describes a special file which was left out of a bag's payload
+type SkippedFile struct {
+	Path   string      // Path relative to the bag root
+	Mode   os.FileMode // The file's mode, which says what kind of special file it is
+	Reason string      // Why it was skipped
+}
+
+func (f *SkippedFile) String() string {
+	return fmt.Sprintf("skipped %q (%s): %s", f.Path, f.Mode.Type(), f.Reason)
+}
+
+// payloadWalker traverses a bag's payload, applying the bag's special file
+// policy. We can't use filepath.Walk, as it never follows symlinks.
+type payloadWalker struct {
+	bag      *Bag
+	fn       func(path string, info os.FileInfo) error
+	visiting map[string]bool // Real paths of the directories we're currently in, to catch symlink loops
+}
+
+func (w *payloadWalker) walkDir(dir string) error {
+	var real, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return fmt.Errorf("unable to resolve %q: %s", dir, err)
+	}
+	w.visiting[real] = true
+	defer delete(w.visiting, real)
+
+	var infos []os.FileInfo
+	infos, err = ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, info := range infos {
+		err = w.walkEntry(filepath.Join(dir, info.Name()), info)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *payloadWalker) walkEntry(path string, info os.FileInfo) error {
+	switch {
+	case info.Mode().IsRegular():
+		return w.fn(path, info)
+	case info.IsDir():
+		return w.walkDir(path)
+	case info.Mode()&os.ModeSymlink != 0 && w.bag.SpecialFiles == FollowSymlinks:
+		return w.followSymlink(path, info)
+	}
+
+	return w.special(path, info, "not a regular file")
+}
+
+func (w *payloadWalker) followSymlink(path string, info os.FileInfo) error {
+	var target, err = os.Stat(path)
+	if err != nil {
+		return w.special(path, info, "broken symlink")
+	}
+
+	if target.IsDir() {
+		var real string
+		real, err = filepath.EvalSymlinks(path)
+		if err != nil {
+			return w.special(path, info, "broken symlink")
+		}
+		if w.visiting[real] {
+			return w.special(path, info, "symlink loop")
+		}
+		return w.walkDir(path)
+	}
+
+	if target.Mode().IsRegular() {
+		return w.fn(path, target)
+	}
+	return w.special(path, info, "symlink to a special file")
+}
+
+// special applies the bag's policy to a special file we aren't going to hash
+func (w *payloadWalker) special(path string, info os.FileInfo, reason string) error {
+	var relPath, err = filepath.Rel(w.bag.root, path)
+	if err != nil {
+		return fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
+	}
+
+	if w.bag.SpecialFiles == FailOnSpecialFiles {
+		return fmt.Errorf("special file %q not allowed in payload: %s", relPath, reason)
+	}
+	w.bag.Skipped = append(w.bag.Skipped, &SkippedFile{Path: relPath, Mode: info.Mode(), Reason: reason})
+	return nil
+}
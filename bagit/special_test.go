@@ -0,0 +1,87 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// makeSymlinkBag returns a test bag with a symlink to a file outside the bag,
+// a symlink to a directory outside the bag, a broken symlink, and a symlink
+// loop
+func makeSymlinkBag(t *testing.T) string {
+	var root = makeTestBag(t)
+	var outside = t.TempDir()
+	var err = ioutil.WriteFile(filepath.Join(outside, "linked.txt"), []byte("linked\n"), 0644)
+	if err == nil {
+		err = os.Symlink(filepath.Join(outside, "linked.txt"), filepath.Join(root, "data", "file-link.txt"))
+	}
+	if err == nil {
+		err = os.Symlink(outside, filepath.Join(root, "data", "dir-link"))
+	}
+	if err == nil {
+		err = os.Symlink(filepath.Join(outside, "nope"), filepath.Join(root, "data", "broken-link"))
+	}
+	if err == nil {
+		err = os.Symlink(outside, filepath.Join(outside, "loop"))
+	}
+	if err != nil {
+		t.Fatalf("Unable to create symlinks: %s", err)
+	}
+	return root
+}
+
+func checksumPaths(sums []*FileChecksum) []string {
+	var paths []string
+	for _, ck := range sums {
+		paths = append(paths, ck.Path)
+	}
+	return paths
+}
+
+func TestSpecialFilesSkip(t *testing.T) {
+	var b = New(makeSymlinkBag(t), hasher.NewMD5())
+	var err = b.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums: %s", err)
+	}
+
+	assert.Equal(2, len(b.ActualChecksums["md5"]), "checksum count", t)
+	assert.Equal(3, len(b.Skipped), "skipped count", t)
+	assert.Equal("data/broken-link", b.Skipped[0].Path, "first skipped file", t)
+	assert.True(b.Skipped[0].Mode&os.ModeSymlink != 0, "skipped file is a symlink", t)
+}
+
+func TestSpecialFilesFollow(t *testing.T) {
+	var b = New(makeSymlinkBag(t), hasher.NewMD5())
+	b.SpecialFiles = FollowSymlinks
+	var err = b.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums: %s", err)
+	}
+
+	var paths = checksumPaths(b.ActualChecksums["md5"])
+	assert.Equal(4, len(paths), "checksum count", t)
+	assert.IncludesString("data/file-link.txt", paths, "followed file link", t)
+	assert.IncludesString("data/dir-link/linked.txt", paths, "followed directory link", t)
+
+	var reasons = make(map[string]string)
+	for _, f := range b.Skipped {
+		reasons[f.Path] = f.Reason
+	}
+	assert.Equal("broken symlink", reasons["data/broken-link"], "broken link", t)
+	assert.Equal("symlink loop", reasons["data/dir-link/loop"], "symlink loop", t)
+}
+
+func TestSpecialFilesFail(t *testing.T) {
+	var b = New(makeSymlinkBag(t), hasher.NewMD5())
+	b.SpecialFiles = FailOnSpecialFiles
+	var err = b.GenerateChecksums()
+	if err == nil {
+		t.Fatalf("Symlinks in the payload should be an error")
+	}
+}
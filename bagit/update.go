@@ -0,0 +1,329 @@
+package bagit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// UpdateResult lists the payload files Update found to have changed since
+// the bag's manifests were written. All paths are relative to the bag root.
+type UpdateResult struct {
+	Added    []string // Files on disk which weren't in the manifests
+	Removed  []string // Files in the manifests which are no longer on disk
+	Modified []string // Files whose checksums no longer match the manifests
+}
+
+// Changed returns true if any files were added, removed, or modified
+func (r *UpdateResult) Changed() bool {
+	return len(r.Added)+len(r.Removed)+len(r.Modified) > 0
+}
+
+// Update brings an existing bag's manifests up to date after its payload has
+// been changed, without rehashing the whole payload. Files which aren't in
+// the manifests are hashed and added, files which are gone are removed, and
+// files modified since the manifests were written (going by their mtime) are
+// rehashed.
+//
+// If the bag has a Cache, a file is only considered unchanged if the cache
+// also has checksums for it which match the manifests; anything else is
+// rehashed. A FileCache throws out entries when a file's size or mtime
+// changes, so this catches files replaced by something with an older mtime,
+// such as a copy made with "cp -p" or "rsync -a". Keeping a FileCache
+// attached to bags you update is strongly recommended.
+//
+// Without a cache, only the mtime is checked, and a file replaced by one with
+// an older mtime won't be noticed. Its stale checksum stays in the manifests,
+// and the tag manifests are computed from those, so only Validate can catch
+// this; use it if you need to be certain.
+//
+// If anything changed, the payload manifests, bag-info.txt (if the bag has
+// one; Payload-Oxum is recomputed if it was set), and tag manifests are
+// rewritten. All new files are fully written to temporary files in the bag
+// before any of them replace the originals, so a failure while generating or
+// writing them leaves the bag untouched. The replacements themselves are
+// separate renames, though, so a failure partway through those can leave a
+// mix of old and new tag files.
+//
+// Update can only rewrite manifests it has a hasher for, so an error is
+// returned, before anything is read, if the bag has a manifest or tag
+// manifest for an algorithm none of b.Hashers use. Open returns a bag with a
+// hasher for every manifest.
+func (b *Bag) Update() (*UpdateResult, error) {
+	return b.UpdateContext(context.Background())
+}
+
+// UpdateContext is Update with support for cancellation and progress
+// reporting; see GenerateChecksumsContext. The bag is left untouched if ctx
+// is canceled.
+func (b *Bag) UpdateContext(ctx context.Context) (*UpdateResult, error) {
+	var err = b.checkHasherCoverage()
+	if err == nil {
+		err = b.ReadManifests()
+	}
+	if err == nil {
+		err = b.ReadBagInfo()
+	}
+	if err == nil {
+		err = b.ReadFetchFile()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var manifestTime time.Time
+	manifestTime, err = b.manifestTime()
+	if err != nil {
+		return nil, err
+	}
+
+	var listed = make(map[string]map[string]string)
+	for _, h := range b.Hashers {
+		for _, ck := range b.ManifestChecksums[h.Name] {
+			if listed[ck.Path] == nil {
+				listed[ck.Path] = make(map[string]string)
+			}
+			listed[ck.Path][h.Name] = ck.Checksum
+		}
+	}
+
+	// Files which are unchanged keep their manifest checksums, while anything
+	// else needs to be hashed
+	var result = &UpdateResult{}
+	var onDisk = make(map[string]bool)
+	var changed []payloadFile
+	b.ActualChecksums = make(map[string][]*FileChecksum)
+	err = b.walkPayload(func(path string, info os.FileInfo) error {
+		var relPath, err = filepath.Rel(b.root, path)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
+		}
+		onDisk[relPath] = true
+
+		var sums = listed[relPath]
+		if sums == nil {
+			result.Added = append(result.Added, relPath)
+		}
+		if sums == nil || !info.ModTime().Before(manifestTime) || !b.cacheConfirms(relPath, sums) {
+			changed = append(changed, payloadFile{path: path, size: info.Size()})
+			return ctx.Err()
+		}
+
+		b.addListedSums(relPath, sums)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var fetched = make(map[string]bool)
+	for _, item := range b.FetchItems {
+		fetched[item.Path] = true
+	}
+	for p, sums := range listed {
+		switch {
+		case onDisk[p]:
+		case fetched[p]:
+			b.addListedSums(p, sums)
+		default:
+			result.Removed = append(result.Removed, p)
+		}
+	}
+
+	var rehashed = make(map[string][]*FileChecksum)
+	err = b.sumFiles(ctx, rehashed, changed, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	var modified = make(map[string]bool)
+	for _, h := range b.Hashers {
+		for _, ck := range rehashed[h.Name] {
+			var old = listed[ck.Path]
+			if old != nil && old[h.Name] != ck.Checksum && !modified[ck.Path] {
+				modified[ck.Path] = true
+				result.Modified = append(result.Modified, ck.Path)
+			}
+			b.ActualChecksums[h.Name] = append(b.ActualChecksums[h.Name], ck)
+		}
+	}
+
+	for _, sums := range b.ActualChecksums {
+		sortSums(sums)
+	}
+	sort.Strings(result.Added)
+	sort.Strings(result.Removed)
+	sort.Strings(result.Modified)
+
+	if !result.Changed() {
+		return result, nil
+	}
+	return result, b.rewriteTagFiles()
+}
+
+// checkHasherCoverage returns an error if the bag has a manifest or tag
+// manifest for an algorithm none of b.Hashers use
+func (b *Bag) checkHasherCoverage() error {
+	var manifests, tagManifests, err = b.ManifestAlgos()
+	if err != nil {
+		return err
+	}
+
+	var have = make(map[string]bool)
+	for _, h := range b.Hashers {
+		have[h.Name] = true
+	}
+	for _, algo := range append(manifests, tagManifests...) {
+		if !have[algo] {
+			return fmt.Errorf("%q has %s manifests, but no %s hasher was given; use Open to get all the bag's hashers", b.root, algo, algo)
+		}
+	}
+	return nil
+}
+
+// cacheConfirms returns false if the bag's Cache can't vouch for a file's
+// manifest checksums: the cache has no entry for some hasher, or its entry
+// doesn't match. Bags without a cache have nothing to check, so their
+// manifests are trusted.
+func (b *Bag) cacheConfirms(relPath string, sums map[string]string) bool {
+	var _, noCache = b.Cache.(noopCache)
+	if noCache {
+		return true
+	}
+
+	for _, h := range b.Hashers {
+		var sum, ok = b.getCachedSum(h.Name, relPath)
+		if !ok || sum != sums[h.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// addListedSums adds a file's manifest checksums to b.ActualChecksums
+func (b *Bag) addListedSums(relPath string, sums map[string]string) {
+	for _, h := range b.Hashers {
+		b.ActualChecksums[h.Name] = append(b.ActualChecksums[h.Name], &FileChecksum{Path: relPath, Checksum: sums[h.Name]})
+	}
+}
+
+// manifestTime returns the modification time of the oldest payload manifest.
+// Anything modified after that may not be reflected in the manifests.
+func (b *Bag) manifestTime() (time.Time, error) {
+	var oldest time.Time
+	for _, h := range b.Hashers {
+		var info, err = os.Stat(b.manifestFilename(h))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unable to stat manifest: %s", err)
+		}
+		if oldest.IsZero() || info.ModTime().Before(oldest) {
+			oldest = info.ModTime()
+		}
+	}
+	return oldest, nil
+}
+
+// rewriteTagFiles regenerates the manifests, bag-info.txt, and tag manifests
+// from b.ActualChecksums. Everything is generated in memory and written to
+// temp files before anything is replaced.
+func (b *Bag) rewriteTagFiles() error {
+	var pending = make(map[string][]byte)
+	for _, h := range b.Hashers {
+		var buf bytes.Buffer
+		var err = b.writeSums(&buf, b.ActualChecksums[h.Name])
+		if err != nil {
+			return fmt.Errorf("error generating manifest: %s", err)
+		}
+		pending[filepath.Base(b.manifestFilename(h))] = buf.Bytes()
+	}
+
+	if b.Info.Len() > 0 {
+		if b.Info.Get(PayloadOxum) != "" {
+			var octets, known, err = b.payloadOctets()
+			if err != nil {
+				return err
+			}
+			if known {
+				b.Info.SetPayloadOxum(octets, int64(len(b.payloadSums())))
+			} else {
+				b.Info.Delete(PayloadOxum)
+			}
+		}
+
+		var buf bytes.Buffer
+		var err = b.Info.Write(&buf)
+		if err != nil {
+			return fmt.Errorf("error generating bag info: %s", err)
+		}
+		pending[filepath.Base(b.bagInfoFilename())] = buf.Bytes()
+	}
+
+	var err = b.generateTagSums(pending, nil, true)
+	if err != nil {
+		return err
+	}
+	for _, h := range b.Hashers {
+		var buf bytes.Buffer
+		err = b.writeSums(&buf, b.ActualTagSums[h.Name])
+		if err != nil {
+			return fmt.Errorf("error generating tag manifest: %s", err)
+		}
+		pending[filepath.Base(b.tagManifestFilename(h))] = buf.Bytes()
+	}
+
+	return b.replaceFiles(pending)
+}
+
+// replaceFiles writes each file's data to a temp file in the bag's root,
+// and only once all of them are written, renames them into place. Each rename
+// is atomic, but the set isn't: if one fails, files renamed before it stay
+// replaced.
+func (b *Bag) replaceFiles(files map[string][]byte) error {
+	var temps = make(map[string]string)
+	var cleanup = func() {
+		for _, tmp := range temps {
+			os.Remove(tmp)
+		}
+	}
+
+	for name, data := range files {
+		var f, err = fileutil.TempFile(b.root, ".bagit-update-", "")
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("unable to create temp file for %q: %s", name, err)
+		}
+		temps[name] = f.Name()
+
+		_, err = f.Write(data)
+		if err == nil {
+			err = f.Chmod(0644)
+		}
+		if err == nil {
+			err = f.Sync()
+		}
+		var cerr = f.Close()
+		if err == nil {
+			err = cerr
+		}
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("unable to write %q: %s", name, err)
+		}
+	}
+
+	for name, tmp := range temps {
+		var err = os.Rename(tmp, filepath.Join(b.root, name))
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("unable to replace %q: %s", name, err)
+		}
+		delete(temps, name)
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+package bagit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestUpdate(t *testing.T) {
+	var root = makeTestBag(t)
+	var b = New(root, hasher.NewSHA256(), hasher.NewMD5())
+	b.Info.SetPayloadOxum(23, 2)
+	b.Info.Set(SourceOrganization, "UO")
+	var err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	var result *UpdateResult
+	result, err = b.Update()
+	if err != nil {
+		t.Fatalf("Unable to update unchanged bag: %s", err)
+	}
+	if result.Changed() {
+		t.Fatalf("Unchanged bag shouldn't report changes: %#v", result)
+	}
+
+	var later = time.Now().Add(time.Hour)
+	var modified = filepath.Join(root, "data", "another.txt")
+	err = ioutil.WriteFile(modified, []byte("changed\n"), 0644)
+	if err == nil {
+		err = os.Chtimes(modified, later, later)
+	}
+	if err == nil {
+		err = os.Remove(filepath.Join(root, "data", "test.txt"))
+	}
+	if err == nil {
+		err = ioutil.WriteFile(filepath.Join(root, "data", "new.txt"), []byte("new file\n"), 0644)
+	}
+	if err != nil {
+		t.Fatalf("Unable to change payload: %s", err)
+	}
+
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	result, err = b.Update()
+	if err != nil {
+		t.Fatalf("Unable to update bag: %s", err)
+	}
+	assert.Equal("data/new.txt", strings.Join(result.Added, ","), "added files", t)
+	assert.Equal("data/test.txt", strings.Join(result.Removed, ","), "removed files", t)
+	assert.Equal("data/another.txt", strings.Join(result.Modified, ","), "modified files", t)
+
+	var leftovers, _ = filepath.Glob(filepath.Join(root, ".bagit-update-*"))
+	assert.Equal(0, len(leftovers), "leftover temp files", t)
+
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate updated bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Updated bag is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+	assert.Equal("17.2", b.Info.Get(PayloadOxum), "Payload-Oxum", t)
+
+	// Payload-Oxum has to keep its place rather than moving to the end
+	var labels []string
+	for _, tag := range b.Info.Tags() {
+		labels = append(labels, tag.Label)
+	}
+	assert.Equal("Payload-Oxum,Source-Organization", strings.Join(labels, ","), "bag-info.txt labels", t)
+}
+
+func TestUpdateWithFileCache(t *testing.T) {
+	var root = makeTestBag(t)
+	var c, err = NewFileCache(filepath.Join(t.TempDir(), "cache.json"), root)
+	if err != nil {
+		t.Fatalf("Unable to create cache: %s", err)
+	}
+
+	var b = New(root, hasher.NewSHA256())
+	b.Cache = c
+	err = b.WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+
+	// Replace a file the way "cp -p" would: new contents, but an mtime from
+	// before the manifests were written
+	var earlier = time.Now().Add(-time.Hour)
+	var replaced = filepath.Join(root, "data", "test.txt")
+	err = ioutil.WriteFile(replaced, []byte("replaced with something longer\n"), 0644)
+	if err == nil {
+		err = os.Chtimes(replaced, earlier, earlier)
+	}
+	if err != nil {
+		t.Fatalf("Unable to replace %q: %s", replaced, err)
+	}
+
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	b.Cache = c
+	var result *UpdateResult
+	result, err = b.Update()
+	if err != nil {
+		t.Fatalf("Unable to update bag: %s", err)
+	}
+	assert.Equal("data/test.txt", strings.Join(result.Modified, ","), "modified files", t)
+
+	var discrepancies []*Discrepancy
+	discrepancies, err = New(root, hasher.NewSHA256()).Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate updated bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Updated bag is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+}
+
+func TestUpdateMissingHasher(t *testing.T) {
+	var root = makeTestBag(t)
+	var err = New(root, hasher.NewSHA256(), hasher.NewMD5()).WriteTagFiles()
+	if err != nil {
+		t.Fatalf("Error writing tag files: %s", err)
+	}
+	err = ioutil.WriteFile(filepath.Join(root, "data", "new.txt"), []byte("new file\n"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to add payload file: %s", err)
+	}
+
+	var before, _ = ioutil.ReadFile(filepath.Join(root, "manifest-sha256.txt"))
+	_, err = New(root, hasher.NewSHA256()).Update()
+	if err == nil {
+		t.Fatalf("Update should fail when the bag has an md5 manifest and no md5 hasher")
+	}
+	var after, _ = ioutil.ReadFile(filepath.Join(root, "manifest-sha256.txt"))
+	assert.Equal(string(before), string(after), "sha256 manifest after failed update", t)
+
+	var b *Bag
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open bag: %s", err)
+	}
+	_, err = b.Update()
+	if err != nil {
+		t.Fatalf("Unable to update bag: %s", err)
+	}
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate updated bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Updated bag is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+}
@@ -0,0 +1,118 @@
+package bagit
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// payloadFile is a file to be hashed, along with its size for progress
+// reporting
+type payloadFile struct {
+	path string
+	size int64
+}
+
+// cloneHashers returns new hashers using the same algorithms as the given
+// list. hasher.Hasher isn't safe for concurrent use, so each worker needs its
+// own set.
+func cloneHashers(list []*hasher.Hasher) ([]*hasher.Hasher, error) {
+	var clones = make([]*hasher.Hasher, len(list))
+	for i, h := range list {
+		clones[i] = h.Clone()
+		if clones[i] == nil {
+			return nil, fmt.Errorf("unable to create a %q hasher", h.Name)
+		}
+	}
+	return clones, nil
+}
+
+// sumFiles computes checksums for all files, appending them to each hasher's
+// list in m. Up to b.Workers files are hashed at once. The first error
+// encountered stops all further hashing and is returned. If ctx is canceled,
+// its error is returned instead.
+//
+// If unreadable is non-nil, errors hashing a file don't stop anything.
+// Instead they're stored in unreadable, keyed by the file's bag-relative
+// path, and the file is left out of m.
+//
+// The bag's Cache is only used if useCache is true.
+//
+// b.OnProgress, if set, is called after each file, and is never called
+// concurrently.
+//
+// Results are not sorted: they come back in whatever order the workers
+// finish.
+func (b *Bag) sumFiles(ctx context.Context, m map[string][]*FileChecksum, files []payloadFile, unreadable map[string]error, useCache bool) error {
+	var workers = b.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var progress = Progress{TotalFiles: int64(len(files))}
+	for _, file := range files {
+		progress.TotalBytes += file.size
+	}
+
+	var jobs = make(chan payloadFile)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for i := 0; i < workers; i++ {
+		var hashers, err = cloneHashers(b.Hashers)
+		if err != nil {
+			close(jobs)
+			wg.Wait()
+			return err
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				var relPath, sums, err = b.getsums(ctx, file.path, hashers, useCache)
+
+				mu.Lock()
+				if err != nil && unreadable != nil && ctx.Err() == nil {
+					var relPath, _ = filepath.Rel(b.root, file.path)
+					unreadable[relPath] = err
+				} else if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				if err == nil {
+					for _, h := range hashers {
+						m[h.Name] = append(m[h.Name], &FileChecksum{Path: relPath, Checksum: sums[h.Name]})
+					}
+					progress.Files++
+					progress.Bytes += file.size
+					progress.Path = relPath
+					if b.OnProgress != nil {
+						b.OnProgress(progress)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, file := range files {
+		mu.Lock()
+		var failed = firstErr != nil
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+		jobs <- file
+	}
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
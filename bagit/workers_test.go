@@ -0,0 +1,43 @@
+package bagit
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestWorkers(t *testing.T) {
+	var root = makeTestBag(t)
+	for i := 0; i < 50; i++ {
+		var fname = filepath.Join(root, "data", fmt.Sprintf("file%02d.txt", i))
+		var err = ioutil.WriteFile(fname, []byte(fmt.Sprintf("contents of file %d", i)), 0644)
+		if err != nil {
+			t.Fatalf("Unable to write test file: %s", err)
+		}
+	}
+
+	var serial = New(root, hasher.NewSHA256(), hasher.NewMD5())
+	var err = serial.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums: %s", err)
+	}
+
+	var parallel = New(root, hasher.NewSHA256(), hasher.NewMD5())
+	parallel.Workers = 8
+	err = parallel.GenerateChecksums()
+	if err != nil {
+		t.Fatalf("Unable to generate checksums in parallel: %s", err)
+	}
+
+	for _, name := range []string{"sha256", "md5"} {
+		var expected, actual = serial.ActualChecksums[name], parallel.ActualChecksums[name]
+		assert.Equal(52, len(actual), name+" checksum count", t)
+		for i := range expected {
+			assert.Equal(*expected[i], *actual[i], fmt.Sprintf("%s checksum %d", name, i), t)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package bagit
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+// BagWriter builds a new bag one payload file at a time, hashing each file
+// as it's copied into the bag. Unlike copying files into "data" and calling
+// WriteTagFiles, the payload is only read once.
+//
+// A BagWriter is not safe for concurrent use.
+type BagWriter struct {
+	// Bag is the bag being written. Its Info, Version, FetchItems, etc. can be
+	// set any time before Close is called.
+	Bag *Bag
+
+	sums   map[string][]*FileChecksum
+	closed bool
+}
+
+// NewBagWriter creates a bag at root with an empty "data" directory, and
+// returns a BagWriter for adding files to it. root may already exist, but
+// must not already have a "data" directory.
+func NewBagWriter(root string, hashers ...*hasher.Hasher) (*BagWriter, error) {
+	var b = New(root, hashers...)
+	var err = b.validateHashers()
+	if err != nil {
+		return nil, err
+	}
+
+	var dataPath = filepath.Join(root, "data")
+	if !fileutil.MustNotExist(dataPath) {
+		return nil, fmt.Errorf("payload directory %q already exists", dataPath)
+	}
+	err = os.MkdirAll(dataPath, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create payload directory %q: %s", dataPath, err)
+	}
+
+	return &BagWriter{Bag: b, sums: make(map[string][]*FileChecksum)}, nil
+}
+
+// payloadPath validates p and returns its bag-relative path
+func payloadPath(p string) (string, error) {
+	if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+		return "", fmt.Errorf("invalid payload path %q: must be a clean, relative path", p)
+	}
+	return "data/" + p, nil
+}
+
+// AddReader copies everything from r into the bag's payload at p, which is
+// relative to the payload directory (e.g., "images/0001.tif"), hashing the
+// data along the way. Any needed subdirectories are created. It's an error
+// to add the same path twice.
+func (w *BagWriter) AddReader(p string, r io.Reader) error {
+	return w.add(p, r, 0644)
+}
+
+// AddFile copies the file at src into the bag's payload at p, preserving the
+// file's permissions. See AddReader.
+func (w *BagWriter) AddFile(p, src string) error {
+	var f, err = os.Open(src)
+	if err != nil {
+		return fmt.Errorf("unable to open %q: %s", src, err)
+	}
+	defer f.Close()
+
+	var info os.FileInfo
+	info, err = f.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat %q: %s", src, err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("unable to add %q: not a regular file", src)
+	}
+
+	return w.add(p, f, info.Mode().Perm())
+}
+
+func (w *BagWriter) add(p string, r io.Reader, mode os.FileMode) error {
+	if w.closed {
+		return fmt.Errorf("cannot add %q: bag writer is closed", p)
+	}
+
+	var relPath, err = payloadPath(p)
+	if err != nil {
+		return err
+	}
+
+	var dest = filepath.Join(w.Bag.root, filepath.FromSlash(relPath))
+	err = os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return fmt.Errorf("unable to create directory for %q: %s", relPath, err)
+	}
+
+	var f *os.File
+	f, err = os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return fmt.Errorf("unable to create %q: %s", relPath, err)
+	}
+
+	var sums map[string]string
+	_, sums, err = copyAndHash(f, r, w.Bag.Hashers)
+	var cerr = f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(dest)
+		return fmt.Errorf("unable to write %q: %s", relPath, err)
+	}
+
+	for _, h := range w.Bag.Hashers {
+		w.sums[h.Name] = append(w.sums[h.Name], &FileChecksum{Path: relPath, Checksum: sums[h.Name]})
+		w.Bag.setCachedSum(h.Name, relPath, sums[h.Name], nil)
+	}
+	return nil
+}
+
+// Close finishes the bag, writing all tag files just as WriteTagFiles would,
+// but using the checksums computed as files were added. Files put into the
+// payload directory by other means won't be in the manifests.
+func (w *BagWriter) Close() error {
+	if w.closed {
+		return fmt.Errorf("bag writer is already closed")
+	}
+	w.closed = true
+
+	for _, sums := range w.sums {
+		sortSums(sums)
+	}
+	w.Bag.ActualChecksums = w.sums
+	return w.Bag.writeTagFiles()
+}
@@ -0,0 +1,56 @@
+package bagit
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/uoregon-libraries/gopkg/assert"
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func TestBagWriter(t *testing.T) {
+	var root = filepath.Join(t.TempDir(), "bag")
+	var w, err = NewBagWriter(root, hasher.NewSHA256(), hasher.NewMD5())
+	if err != nil {
+		t.Fatalf("Unable to create bag writer: %s", err)
+	}
+	w.Bag.Info.Set(SourceOrganization, "UO")
+
+	err = w.AddReader("sub/from-reader.txt", strings.NewReader("reader data\n"))
+	if err != nil {
+		t.Fatalf("Unable to add reader: %s", err)
+	}
+	err = w.AddFile("test.txt", filepath.Join("testdata", "data", "test.txt"))
+	if err != nil {
+		t.Fatalf("Unable to add file: %s", err)
+	}
+
+	for _, p := range []string{"test.txt", "../escape.txt", "/abs.txt", "sub/../test.txt"} {
+		err = w.AddReader(p, strings.NewReader("x"))
+		if err == nil {
+			t.Fatalf("Adding %q should be an error", p)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatalf("Unable to close bag writer: %s", err)
+	}
+	assert.Equal("b05403212c66bdc8ccc597fedf6cd5fe", w.Bag.ActualChecksums["md5"][1].Checksum, "test.txt checksum", t)
+
+	var b *Bag
+	b, err = Open(root)
+	if err != nil {
+		t.Fatalf("Unable to open written bag: %s", err)
+	}
+	var discrepancies []*Discrepancy
+	discrepancies, err = b.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate written bag: %s", err)
+	}
+	if len(discrepancies) > 0 {
+		t.Fatalf("Written bag is invalid: %s", joinDiscrepancies(discrepancies))
+	}
+	assert.Equal("22.2", b.Info.Get(PayloadOxum), "Payload-Oxum", t)
+}
@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/uoregon-libraries/gopkg/bagit"
+	"github.com/uoregon-libraries/gopkg/fileutil"
 	"github.com/uoregon-libraries/gopkg/hasher"
+	"github.com/uoregon-libraries/gopkg/interrupts"
 )
 
 func perr(msg string) {
@@ -22,66 +26,217 @@ func usage(err string) {
 		perr("")
 	}
 
-	perrf("Usage: %s <operation> <algorithm> <path to bag directory>", os.Args[0])
+	perrf("Usage: %s write <algorithm>[,<algorithm>...] <path to bag directory>", os.Args[0])
+	perrf("       %s validate <path to bag directory or serialized bag> [<algorithm>[,<algorithm>...]]", os.Args[0])
+	perrf("       %s validate-complete <path to bag directory>", os.Args[0])
+	perrf("       %s serialize <path to bag directory> <archive file>", os.Args[0])
+	perrf("       %s update <path to bag directory>", os.Args[0])
 	perr("")
-	perr(`<operation> must be either "write" or "validate"`)
-	perr(`<algorithm> must be one of: "md5", "sha1", "sha256", "sha512"`)
+	perrf("<algorithm> must be one of: %s", algoList())
+	perr("")
+	perr("Validation detects which algorithms the bag uses from its manifest files")
+	perr("unless they're given. Giving them saves a second read of tar archives whose")
+	perr("payload comes before the manifests.")
+	perr("validate-complete reports every problem it finds, including unreadable")
+	perr("files, instead of stopping at the first broken manifest.")
+	perr(`Serialized bags must end in ".tar", ".tar.gz", ".tgz", or ".zip".`)
 	os.Exit(1)
 }
 
 func main() {
-	if len(os.Args) != 4 {
+	if len(os.Args) < 2 {
 		usage("invalid arguments")
 	}
 
-	var op, algo, fname = os.Args[1], os.Args[2], os.Args[3]
+	var ctx, cancel = context.WithCancel(context.Background())
+	interrupts.TrapIntTerm(cancel)
 
-	var h *hasher.Hasher
-	switch algo {
-	case "md5":
-		h = hasher.NewMD5()
-	case "sha1":
-		h = hasher.NewSHA1()
-	case "sha256":
-		h = hasher.NewSHA256()
-	case "sha512":
-		h = hasher.NewSHA512()
-	default:
-		usage("invalid algorithm: " + algo)
-	}
-
-	switch op {
+	switch os.Args[1] {
 	case "write":
-		write(fname, h)
+		if len(os.Args) != 4 {
+			usage("invalid arguments")
+		}
+		write(ctx, os.Args[3], getHashers(os.Args[2]))
 	case "validate":
+		if len(os.Args) != 3 && len(os.Args) != 4 {
+			usage("invalid arguments")
+		}
+		var hashers []*hasher.Hasher
+		if len(os.Args) == 4 {
+			hashers = getHashers(os.Args[3])
+		}
+		var fname = os.Args[2]
+		fmt.Println("Validating bag at ", fname)
+		validate(ctx, fname, hashers)
+		fmt.Println("Valid")
+	case "validate-complete":
+		if len(os.Args) != 3 {
+			usage("invalid arguments")
+		}
+		var fname = os.Args[2]
 		fmt.Println("Validating bag at ", fname)
-		validate(fname, h)
+		validateComplete(ctx, fname)
 		fmt.Println("Valid")
+	case "serialize":
+		if len(os.Args) != 4 {
+			usage("invalid arguments")
+		}
+		serialize(os.Args[2], os.Args[3])
+	case "update":
+		if len(os.Args) != 3 {
+			usage("invalid arguments")
+		}
+		update(ctx, os.Args[2])
 	default:
-		usage("invalid operation: " + op)
+		usage("invalid operation: " + os.Args[1])
 	}
 }
 
-func write(path string, h *hasher.Hasher) {
-	var b = bagit.New(path, h)
-	var err = b.WriteTagFiles()
+func algoList() string {
+	var names []string
+	for _, a := range hasher.Algos() {
+		names = append(names, fmt.Sprintf("%q", a))
+	}
+	return strings.Join(names, ", ")
+}
+
+func getHashers(algos string) []*hasher.Hasher {
+	var list []*hasher.Hasher
+	for _, algo := range strings.Split(algos, ",") {
+		var h = hasher.FromString(algo)
+		if h == nil {
+			usage("invalid algorithm: " + algo)
+		}
+		list = append(list, h)
+	}
+	return list
+}
+
+// showProgress prints a status line to stderr every so often
+func showProgress(p bagit.Progress) {
+	if p.Files%100 == 0 || p.Files == p.TotalFiles {
+		perrf("Hashed %d of %d files (%d of %d bytes)", p.Files, p.TotalFiles, p.Bytes, p.TotalBytes)
+	}
+}
+
+func write(ctx context.Context, path string, hashers []*hasher.Hasher) {
+	var b = bagit.New(path, hashers...)
+	b.OnProgress = showProgress
+	var err = b.WriteTagFilesContext(ctx)
 	if err != nil {
 		perrf("Error generating tag files for %q: %s", path, err)
 	}
+	warnSkipped(b)
+}
+
+// warnSkipped lets the user know about any symlinks or other special files
+// which didn't make it into the manifests
+func warnSkipped(b *bagit.Bag) {
+	for _, f := range b.Skipped {
+		perrf("Warning: %s", f)
+	}
+}
+
+func serialize(path, archive string) {
+	var err = bagit.New(path).SerializeFile(archive)
+	if err != nil {
+		perrf("Error serializing %q to %q: %s", path, archive, err)
+		os.Exit(255)
+	}
+}
+
+func update(ctx context.Context, path string) {
+	var b, err = bagit.Open(path)
+	if err != nil {
+		perrf("Error trying to open %q: %s", path, err)
+		os.Exit(255)
+	}
+
+	b.OnProgress = showProgress
+	var result *bagit.UpdateResult
+	result, err = b.UpdateContext(ctx)
+	if err != nil {
+		perrf("Error updating %q: %s", path, err)
+		os.Exit(255)
+	}
+
+	for _, p := range result.Added {
+		fmt.Println("Added:", p)
+	}
+	for _, p := range result.Removed {
+		fmt.Println("Removed:", p)
+	}
+	for _, p := range result.Modified {
+		fmt.Println("Modified:", p)
+	}
+	if !result.Changed() {
+		fmt.Println("No changes")
+	}
 }
 
-func validate(path string, h *hasher.Hasher) {
-	var b = bagit.New(path, h)
-	var discrepancies, err = b.Validate()
+func validate(ctx context.Context, path string, hashers []*hasher.Hasher) {
+	if !fileutil.IsDir(path) {
+		validateSerialized(ctx, path, hashers)
+		return
+	}
+
+	var b *bagit.Bag
+	var err error
+	if len(hashers) > 0 {
+		b = bagit.New(path, hashers...)
+	} else {
+		b, err = bagit.Open(path)
+	}
+	if err != nil {
+		perrf("Error trying to open %q: %s", path, err)
+		os.Exit(255)
+	}
+
+	b.OnProgress = showProgress
+	var discrepancies []*bagit.Discrepancy
+	discrepancies, err = b.ValidateContext(ctx)
+	warnSkipped(b)
 	if err != nil {
 		perrf("Error trying to validate %q: %s", path, err)
 		os.Exit(255)
 	}
 
+	reportDiscrepancies(discrepancies)
+}
+
+func validateComplete(ctx context.Context, path string) {
+	var b, err = bagit.Open(path)
+	if err != nil {
+		perrf("Error trying to open %q: %s", path, err)
+		os.Exit(255)
+	}
+
+	b.OnProgress = showProgress
+	var discrepancies []*bagit.Discrepancy
+	discrepancies, err = b.ValidateCompleteContext(ctx)
+	warnSkipped(b)
+	if err != nil {
+		perrf("Error trying to validate %q: %s", path, err)
+		os.Exit(255)
+	}
+
+	reportDiscrepancies(discrepancies)
+}
+
+func validateSerialized(ctx context.Context, path string, hashers []*hasher.Hasher) {
+	var discrepancies, err = bagit.ValidateSerializedContext(ctx, path, hashers...)
+	if err != nil {
+		perrf("Error trying to validate %q: %s", path, err)
+		os.Exit(255)
+	}
+	reportDiscrepancies(discrepancies)
+}
+
+func reportDiscrepancies(discrepancies []*bagit.Discrepancy) {
 	if len(discrepancies) > 0 {
 		perr("Bag is invalid:")
-		for _, txt := range discrepancies {
-			perrf("  - %s", txt)
+		for _, d := range discrepancies {
+			perrf("  - %s", d)
 		}
 		os.Exit(1)
 	}
@@ -0,0 +1,98 @@
+// This is a simple sha256sum / md5sum work-alike for verifying and creating
+// checksum lists with the hasher package
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func usage(msg string) {
+	fmt.Fprintln(os.Stderr, "\033[31;1mError:\033[0m "+msg)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintf(os.Stderr, "usage: %s verify <checksum file>...\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "       %s create <algorithm> <checksum file> [--bsd]\n\n", os.Args[0])
+	fmt.Fprintln(os.Stderr, `"verify" checks every file listed, relative to the checksum file's`)
+	fmt.Fprintln(os.Stderr, `directory. GNU (sha256sum) and BSD ("SHA256 (file) = ...") formats are`)
+	fmt.Fprintln(os.Stderr, `both understood.`)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, `"create" hashes every file under the checksum file's directory, writing`)
+	fmt.Fprintln(os.Stderr, `a GNU-style list unless --bsd is given. <algorithm> is one of:`)
+	for _, a := range hasher.Algos() {
+		fmt.Fprintf(os.Stderr, "  - %s\n", a)
+	}
+
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		usage("invalid arguments")
+	}
+
+	switch os.Args[1] {
+	case "verify":
+		verify(os.Args[2:])
+	case "create":
+		if len(os.Args) < 4 || len(os.Args) > 5 {
+			usage("invalid arguments")
+		}
+		var format = hasher.GNUFormat
+		if len(os.Args) == 5 {
+			if os.Args[4] != "--bsd" {
+				usage("invalid option " + os.Args[4])
+			}
+			format = hasher.BSDFormat
+		}
+		create(os.Args[2], os.Args[3], format)
+	default:
+		usage(fmt.Sprintf("invalid operation %q", os.Args[1]))
+	}
+}
+
+func verify(files []string) {
+	var failed, missing int
+	for _, fname := range files {
+		var results, err = hasher.VerifyChecksumFile(fname)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Unable to verify %q: %s\n", fname, err)
+			os.Exit(1)
+		}
+
+		for _, r := range results {
+			fmt.Println(r)
+			switch r.Status {
+			case hasher.CheckFailed:
+				failed++
+			case hasher.CheckMissing:
+				missing++
+			}
+		}
+	}
+
+	if failed > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d computed checksum(s) did NOT match\n", failed)
+	}
+	if missing > 0 {
+		fmt.Fprintf(os.Stderr, "WARNING: %d listed file(s) could not be found\n", missing)
+	}
+	if failed+missing > 0 {
+		os.Exit(1)
+	}
+}
+
+func create(algo, fname string, format hasher.ListFormat) {
+	var h = hasher.FromString(algo)
+	if h == nil {
+		usage(fmt.Sprintf("invalid algorithm %q", algo))
+	}
+
+	var err = hasher.WriteChecksumFile(fname, h, format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Unable to create %q: %s\n", fname, err)
+		os.Exit(1)
+	}
+}
@@ -16,7 +16,10 @@ func usage(msg string) {
 	fmt.Fprintln(os.Stderr)
 	fmt.Fprintf(os.Stderr, "usage: %s <operation> <directory>...\n\n", os.Args[0])
 	fmt.Fprintln(os.Stderr, `Operation may be either "create" or "verify". "create" may optionally be`)
-	fmt.Fprintln(os.Stderr, `suffixed with "-<algo>", where algo is md5, sha256, etc.`)
+	fmt.Fprintln(os.Stderr, `suffixed with "-<algo>", where algo is one of:`)
+	for _, a := range hasher.Algos() {
+		fmt.Fprintf(os.Stderr, "  - %s\n", a)
+	}
 
 	os.Exit(-1)
 }
@@ -77,16 +80,20 @@ func verify(dirs []string) {
 			continue
 		}
 
-		var valid bool
-		valid, err = m.Validate()
+		var changes []*manifest.Change
+		changes, err = m.Changes()
 		if err != nil {
 			log.Printf("Unable to validate manifest for %q: %s", dir, err)
 			continue
 		}
-		if valid {
+		if len(changes) == 0 {
 			log.Printf("OK %q", dir)
-		} else {
-			log.Printf("NOT OK %q", dir)
+			continue
+		}
+
+		log.Printf("NOT OK %q", dir)
+		for _, c := range changes {
+			log.Printf("  - %s", c)
 		}
 	}
 }
@@ -0,0 +1,116 @@
+package manifest
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// ChangeKind tells us what sort of difference a Change describes
+type ChangeKind int
+
+// All kinds of changes Diff can report
+const (
+	Added          ChangeKind = iota + 1 // File is only in the new manifest
+	Removed                              // File is only in the old manifest
+	Resized                              // File's size changed
+	ContentChanged                       // File's checksum changed (only reported when both manifests are hashed)
+	ModeChanged                          // File's mode (permissions or type) changed
+	ModTimeChanged                       // File's modification time changed
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Resized:
+		return "resized"
+	case ContentChanged:
+		return "content changed"
+	case ModeChanged:
+		return "mode changed"
+	case ModTimeChanged:
+		return "modification time changed"
+	}
+	return fmt.Sprintf("unknown change (%d)", int(k))
+}
+
+// A Change is a single difference between two manifests. A file with
+// several differences (e.g., a new size, checksum, and modification time)
+// gets a Change for each.
+type Change struct {
+	Kind ChangeKind
+	Name string
+	Old  *FileInfo // The file in the old manifest; nil if the file was added
+	New  *FileInfo // The file in the new manifest; nil if the file was removed
+}
+
+// String returns a human-readable description of the change
+func (c *Change) String() string {
+	switch c.Kind {
+	case Added, Removed, ContentChanged:
+		return fmt.Sprintf("%q: %s", c.Name, c.Kind)
+	case Resized:
+		return fmt.Sprintf("%q: %s from %d to %d bytes", c.Name, c.Kind, c.Old.Size, c.New.Size)
+	case ModeChanged:
+		return fmt.Sprintf("%q: %s from %s to %s", c.Name, c.Kind, c.Old.Mode, c.New.Mode)
+	case ModTimeChanged:
+		return fmt.Sprintf("%q: %s from %s to %s", c.Name, c.Kind, c.Old.ModTime.Format(time.RFC3339Nano), c.New.ModTime.Format(time.RFC3339Nano))
+	}
+	return fmt.Sprintf("%q: %s", c.Name, c.Kind)
+}
+
+// Diff returns every difference between m (the "old" manifest) and m2 (the
+// "new" one), sorted by file name, then kind. The comparisons are the same
+// ones Equiv makes, so Diff returns an empty list exactly when Equiv returns
+// true.
+func (m *Manifest) Diff(m2 *Manifest) []*Change {
+	var oldFiles = make(map[string]*FileInfo, len(m.Files))
+	for i := range m.Files {
+		oldFiles[m.Files[i].Name] = &m.Files[i]
+	}
+	var newFiles = make(map[string]*FileInfo, len(m2.Files))
+	for i := range m2.Files {
+		newFiles[m2.Files[i].Name] = &m2.Files[i]
+	}
+
+	var changes []*Change
+	for name, f1 := range oldFiles {
+		var f2 = newFiles[name]
+		if f2 == nil {
+			changes = append(changes, &Change{Kind: Removed, Name: name, Old: f1})
+			continue
+		}
+
+		var add = func(k ChangeKind) {
+			changes = append(changes, &Change{Kind: k, Name: name, Old: f1, New: f2})
+		}
+		if f1.Size != f2.Size {
+			add(Resized)
+		}
+		if f1.Sum != f2.Sum && f1.Sum != "" && f2.Sum != "" {
+			add(ContentChanged)
+		}
+		if f1.Mode != f2.Mode {
+			add(ModeChanged)
+		}
+		if !f1.ModTime.Equal(f2.ModTime) {
+			add(ModTimeChanged)
+		}
+	}
+	for name, f2 := range newFiles {
+		if oldFiles[name] == nil {
+			changes = append(changes, &Change{Kind: Added, Name: name, New: f2})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Name != changes[j].Name {
+			return changes[i].Name < changes[j].Name
+		}
+		return changes[i].Kind < changes[j].Kind
+	})
+	return changes
+}
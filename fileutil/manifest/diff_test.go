@@ -0,0 +1,105 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/uoregon-libraries/gopkg/hasher"
+)
+
+func joinChanges(changes []*Change) string {
+	var list []string
+	for _, c := range changes {
+		list = append(list, c.String())
+	}
+	return strings.Join(list, "\n")
+}
+
+func TestDiff(t *testing.T) {
+	var t1 = time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC)
+	var t2 = time.Date(1999, 1, 2, 0, 0, 0, 0, time.UTC)
+	var m1 = &Manifest{Files: []FileInfo{
+		{Name: "same", Sum: "a", Size: 1, Mode: 0644, ModTime: t1},
+		{Name: "removed", Sum: "a", Size: 1, Mode: 0644, ModTime: t1},
+		{Name: "rewritten", Sum: "a", Size: 1, Mode: 0644, ModTime: t1},
+		{Name: "chmod", Sum: "a", Size: 1, Mode: 0644, ModTime: t1},
+		{Name: "unhashed", Size: 1, Mode: 0644, ModTime: t1},
+	}}
+	var m2 = &Manifest{Files: []FileInfo{
+		{Name: "added", Sum: "a", Size: 1, Mode: 0644, ModTime: t1},
+		{Name: "chmod", Sum: "a", Size: 1, Mode: 0600, ModTime: t1},
+		{Name: "rewritten", Sum: "b", Size: 2, Mode: 0644, ModTime: t2},
+		{Name: "same", Sum: "a", Size: 1, Mode: 0644, ModTime: t1.In(time.FixedZone("PST", -8*3600))},
+		{Name: "unhashed", Sum: "b", Size: 1, Mode: 0644, ModTime: t1},
+	}}
+
+	var expected = strings.Join([]string{
+		`"added": added`,
+		`"chmod": mode changed from -rw-r--r-- to -rw-------`,
+		`"removed": removed`,
+		`"rewritten": resized from 1 to 2 bytes`,
+		`"rewritten": content changed`,
+		`"rewritten": modification time changed from 1999-01-01T00:00:00Z to 1999-01-02T00:00:00Z`,
+	}, "\n")
+	var got = joinChanges(m1.Diff(m2))
+	if got != expected {
+		t.Errorf("Diff() returned:\n%s\n\nwant:\n%s", got, expected)
+	}
+
+	var changes = m1.Diff(m2)
+	if changes[0].Old != nil || changes[0].New == nil {
+		t.Errorf("Added file should only have New set: %#v", changes[0])
+	}
+	if changes[2].Old == nil || changes[2].New != nil {
+		t.Errorf("Removed file should only have Old set: %#v", changes[2])
+	}
+
+	if len(m1.Diff(m1)) != 0 {
+		t.Errorf("A manifest shouldn't differ from itself")
+	}
+	if m1.Equiv(m2) {
+		t.Errorf("Manifests with changes shouldn't be equivalent")
+	}
+}
+
+func TestChanges(t *testing.T) {
+	var dir = t.TempDir()
+	for _, f := range []string{"a.txt", "b.txt"} {
+		var err = os.WriteFile(filepath.Join(dir, f), []byte(f), 0644)
+		if err != nil {
+			t.Fatalf("Unable to write %q: %s", f, err)
+		}
+	}
+
+	var m, err = BuildHashed(dir, hasher.NewMD5())
+	if err != nil {
+		t.Fatalf("Unable to build manifest: %s", err)
+	}
+
+	var changes []*Change
+	changes, err = m.Changes()
+	if err != nil {
+		t.Fatalf("Unable to check for changes: %s", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("Unchanged directory reported changes: %s", joinChanges(changes))
+	}
+
+	os.Remove(filepath.Join(dir, "a.txt"))
+	err = os.WriteFile(filepath.Join(dir, "c.txt"), nil, 0644)
+	if err != nil {
+		t.Fatalf("Unable to write c.txt: %s", err)
+	}
+	changes, err = m.Changes()
+	if err != nil {
+		t.Fatalf("Unable to check for changes: %s", err)
+	}
+	var expected = `"a.txt": removed` + "\n" + `"c.txt": added`
+	var got = joinChanges(changes)
+	if got != expected {
+		t.Errorf("Changes() returned:\n%s\n\nwant:\n%s", got, expected)
+	}
+}
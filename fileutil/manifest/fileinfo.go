@@ -42,13 +42,20 @@ func (fi FileInfo) Equal(b FileInfo) bool {
 	return true
 }
 
-func newFileInfo(loc string, e os.DirEntry, h *hasher.Hasher) (FileInfo, error) {
-	var fullpath = filepath.Join(loc, e.Name())
-	var fd = FileInfo{Name: e.Name()}
+// newFileInfo returns the FileInfo for e, which is at name (a "/"-separated
+// path) relative to loc. Directories only get a name and mode.
+func newFileInfo(loc, name string, e os.DirEntry, h *hasher.Hasher) (FileInfo, error) {
+	var fullpath = filepath.Join(loc, filepath.FromSlash(name))
+	var fd = FileInfo{Name: name}
 	var info, err = e.Info()
 	if err != nil {
 		return fd, fmt.Errorf("reading info for %q: %w", fullpath, err)
 	}
+	fd.Mode = info.Mode()
+	if info.IsDir() {
+		return fd, nil
+	}
+
 	if h != nil {
 		fd.Sum, err = h.FileSum(fullpath)
 		if err != nil {
@@ -57,7 +64,6 @@ func newFileInfo(loc string, e os.DirEntry, h *hasher.Hasher) (FileInfo, error)
 	}
 
 	fd.Size = info.Size()
-	fd.Mode = info.Mode()
 	fd.ModTime = info.ModTime()
 
 	return fd, nil
@@ -3,10 +3,12 @@ package manifest
 import (
 	"encoding/json"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"sync"
 	"time"
 
 	"github.com/uoregon-libraries/gopkg/hasher"
@@ -16,10 +18,11 @@ import (
 const Filename = ".manifest"
 
 // A Manifest is a somewhat special-case representation of a filesystem
-// directory's state. It only works with very simple directories: no subdirs,
-// no special files, etc. Hidden files are ignored from the Manifest by design
-// to allow for the common cases without getting problems from things like a
-// .gitignore file for instance.
+// directory's state. It only works with very simple directories: no special
+// files, and no subdirs unless Recursive is set. Hidden files (and in
+// recursive manifests, hidden directories) are ignored from the Manifest by
+// design to allow for the common cases without getting problems from things
+// like a .gitignore file for instance.
 //
 // The data stored can be useful to determine if a directory changes
 // purposefully: filesize, file modes (permissions) and file's modification
@@ -32,6 +35,19 @@ type Manifest struct {
 	Files    []FileInfo
 	HashAlgo string
 	Hasher   *hasher.Hasher `json:"-"`
+
+	// Recursive makes Build include subdirectories rather than failing when
+	// it finds one. Each file's Name is its path relative to the manifest's
+	// location, using "/" separators, and each subdirectory gets an entry of
+	// its own. Directory entries only store the directory's mode, as a
+	// directory's size and modification time change whenever its contents
+	// do. This is stored in the manifest file so Validate knows how to
+	// rebuild it.
+	Recursive bool `json:",omitempty"`
+
+	// Workers is the number of files to hash at once when building a hashed
+	// manifest. Values below 1 mean one file at a time.
+	Workers int `json:"-"`
 }
 
 // New returns a Manifest ready for scanning a directory or reading an existing
@@ -59,6 +75,19 @@ func BuildHashed(location string, h *hasher.Hasher) (*Manifest, error) {
 	return m, err
 }
 
+// BuildRecursive builds a Recursive manifest for the given location,
+// hashing files with h unless it's nil
+func BuildRecursive(location string, h *hasher.Hasher) (*Manifest, error) {
+	var m = New(location)
+	m.Recursive = true
+	m.Hasher = h
+	if h != nil {
+		m.HashAlgo = h.Name
+	}
+	var err = m.Build()
+	return m, err
+}
+
 // Open looks for a manifest file in the given location, and returns a Manifest
 // or an error (e.g., no manifest file existed)
 func Open(location string) (*Manifest, error) {
@@ -84,30 +113,139 @@ func Open(location string) (*Manifest, error) {
 
 // Build reads all files in the manifest's path and builds our manifest data.
 func (m *Manifest) Build() error {
-	var entries, err = os.ReadDir(m.path)
+	var entries []dirEntry
+	var err error
+	if m.Recursive {
+		entries, err = m.walk()
+	} else {
+		entries, err = m.readDir()
+	}
 	if err != nil {
 		return fmt.Errorf("reading dir %q: %w", m.path, err)
 	}
 
+	var infos []FileInfo
+	infos, err = m.fileInfos(entries)
+	if err != nil {
+		return fmt.Errorf("reading dir %q: %w", m.path, err)
+	}
+	m.Files = append(m.Files, infos...)
+	return nil
+}
+
+// dirEntry is a file or directory to be put into the manifest, along with
+// its name relative to the manifest's path
+type dirEntry struct {
+	name  string
+	entry os.DirEntry
+}
+
+// skip returns true for the manifest file and any hidden files - we
+// explicitly check for the manifest in case we change the constant string to
+// not be hidden for some reason.
+func skip(name string) bool {
+	return name[0] == '.' || name == Filename
+}
+
+// readDir returns the entries in m.path, failing if there are any
+// subdirectories or special files
+func (m *Manifest) readDir() ([]dirEntry, error) {
+	var entries, err = os.ReadDir(m.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []dirEntry
 	for _, entry := range entries {
 		if !entry.Type().IsRegular() {
-			return fmt.Errorf("reading dir %q: one or more entries are not a regular file", m.path)
+			return nil, fmt.Errorf("one or more entries are not a regular file")
 		}
-
-		// Skip the manifest as well as any hidden files - we explicitly check for
-		// the manifest in case we change the constant string to not be hidden for
-		// some reason.
-		if entry.Name()[0] == '.' || entry.Name() == Filename {
+		if skip(entry.Name()) {
 			continue
 		}
+		list = append(list, dirEntry{name: entry.Name(), entry: entry})
+	}
+	return list, nil
+}
+
+// walk returns all files and directories under m.path, failing if there are
+// any special files. Hidden directories are skipped entirely.
+func (m *Manifest) walk() ([]dirEntry, error) {
+	var list []dirEntry
+	var err = filepath.WalkDir(m.path, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == m.path {
+			return nil
+		}
 
-		var fd, err = newFileInfo(m.path, entry, m.Hasher)
+		if skip(entry.Name()) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && !entry.Type().IsRegular() {
+			return fmt.Errorf("%q is not a regular file or directory", path)
+		}
+
+		var rel string
+		rel, err = filepath.Rel(m.path, path)
 		if err != nil {
-			return fmt.Errorf("reading dir %q: %w", m.path, err)
+			return fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
+		}
+		list = append(list, dirEntry{name: filepath.ToSlash(rel), entry: entry})
+		return nil
+	})
+	return list, err
+}
+
+// fileInfos returns a FileInfo for each entry, in the same order, using up to
+// m.Workers goroutines. Each goroutine gets its own clone of m.Hasher, as
+// hashers aren't safe for concurrent use.
+func (m *Manifest) fileInfos(entries []dirEntry) ([]FileInfo, error) {
+	var workers = m.Workers
+	if workers < 1 || m.Hasher == nil {
+		workers = 1
+	}
+
+	var infos = make([]FileInfo, len(entries))
+	var errs = make([]error, len(entries))
+	var jobs = make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		var h = m.Hasher
+		if h != nil && workers > 1 {
+			h = h.Clone()
+			if h == nil {
+				close(jobs)
+				wg.Wait()
+				return nil, fmt.Errorf("unable to create a %q hasher", m.Hasher.Name)
+			}
 		}
-		m.Files = append(m.Files, fd)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				infos[i], errs[i] = newFileInfo(m.path, entries[i].name, entries[i].entry, h)
+			}
+		}()
 	}
-	return nil
+
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
 }
 
 func (m *Manifest) filename() string {
@@ -142,14 +280,40 @@ func (m *Manifest) sortFiles() {
 // This can return an error for the same reasons Build can: particularly if the
 // path is not valid or there are non-file directory entries in the path.
 func (m *Manifest) Validate() (bool, error) {
-	var m2, err = BuildHashed(m.path, hasher.FromString(m.HashAlgo))
+	var m2, err = m.rebuild()
 	if err != nil {
 		return false, err
 	}
 	return m.Equiv(m2), nil
 }
 
-// Equiv returns true if m and m2 have the *exact* same file lists.
+// Changes is like Validate, but returns a list of everything that's changed
+// in the directory since m was built; see Diff. An empty list means the
+// directory is unchanged.
+func (m *Manifest) Changes() ([]*Change, error) {
+	var m2, err = m.rebuild()
+	if err != nil {
+		return nil, err
+	}
+	return m.Diff(m2), nil
+}
+
+// rebuild builds a new manifest for m's path, using m's settings
+func (m *Manifest) rebuild() (*Manifest, error) {
+	var m2 = New(m.path)
+	m2.Hasher = hasher.FromString(m.HashAlgo)
+	m2.Recursive = m.Recursive
+	m2.Workers = m.Workers
+	var err = m2.Build()
+	if err != nil {
+		return nil, err
+	}
+	return m2, nil
+}
+
+// Equiv returns true if m and m2 have the *exact* same file lists. For
+// recursive manifests, this means the entire tree must match, including the
+// modes of subdirectories.
 // Struct requires manual comparison as ModTime values must use Equal
 // to handle monotonic clock values. (Ref: https://pkg.go.dev/time)
 func (m *Manifest) Equiv(m2 *Manifest) bool {
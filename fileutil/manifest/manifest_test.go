@@ -271,3 +271,141 @@ func TestValidateOneSidedHash(t *testing.T) {
 		t.Errorf("Manifests shouldn't be equivalent when hashing is on for both")
 	}
 }
+
+func TestBuildWorkers(t *testing.T) {
+	var serial, err = BuildHashed(testdir(t), hasher.NewSHA256())
+	if err != nil {
+		t.Fatalf("Unable to build with hash: %s", err)
+	}
+
+	var m = New(testdir(t))
+	m.Hasher = hasher.NewSHA256()
+	m.Workers = 4
+	err = m.Build()
+	if err != nil {
+		t.Fatalf("Unable to build with workers: %s", err)
+	}
+
+	if len(m.Files) != len(serial.Files) {
+		t.Fatalf("Expected %d files, got %d", len(serial.Files), len(m.Files))
+	}
+	for i := range m.Files {
+		if !m.Files[i].Equal(serial.Files[i]) || m.Files[i].Sum != serial.Files[i].Sum {
+			t.Errorf("Expected m.Files[%d] to be %#v, got %#v", i, serial.Files[i], m.Files[i])
+		}
+	}
+
+	var ok bool
+	ok, err = m.Validate()
+	if err != nil {
+		t.Fatalf("Unable to validate: %s", err)
+	}
+	if !ok {
+		t.Errorf("Manifest should validate")
+	}
+}
+
+func TestBuildRecursive(t *testing.T) {
+	var dir = t.TempDir()
+	for _, d := range []string{"issue1/page1", "issue1/page2", ".hidden"} {
+		var err = os.MkdirAll(filepath.Join(dir, filepath.FromSlash(d)), 0755)
+		if err != nil {
+			t.Fatalf("Unable to create %q: %s", d, err)
+		}
+	}
+	for _, f := range []string{"top.txt", "issue1/page1/a.txt", "issue1/page2/b.txt", "issue1/.skipme", ".hidden/c.txt"} {
+		var err = os.WriteFile(filepath.Join(dir, filepath.FromSlash(f)), []byte(f), 0644)
+		if err != nil {
+			t.Fatalf("Unable to write %q: %s", f, err)
+		}
+	}
+
+	var _, err = Build(dir)
+	if err == nil {
+		t.Fatalf("Non-recursive build should fail when there are subdirectories")
+	}
+
+	var m *Manifest
+	m, err = BuildRecursive(dir, hasher.NewSHA256())
+	if err != nil {
+		t.Fatalf("Unable to build recursive manifest: %s", err)
+	}
+	m.sortFiles()
+	var expected = []string{"issue1", "issue1/page1", "issue1/page1/a.txt", "issue1/page2", "issue1/page2/b.txt", "top.txt"}
+	if len(m.Files) != len(expected) {
+		t.Fatalf("Expected %d entries, got %#v", len(expected), m.Files)
+	}
+	for i, name := range expected {
+		if m.Files[i].Name != name {
+			t.Errorf("Expected m.Files[%d] to be %q, got %q", i, name, m.Files[i].Name)
+		}
+	}
+	if !m.Files[0].Mode.IsDir() || m.Files[0].Sum != "" || !m.Files[0].ModTime.IsZero() {
+		t.Errorf("Directory entry should only have a name and mode: %#v", m.Files[0])
+	}
+	if m.Files[2].Sum == "" {
+		t.Errorf("Nested file should be hashed: %#v", m.Files[2])
+	}
+
+	err = m.Write()
+	if err != nil {
+		t.Fatalf("Unable to write manifest: %s", err)
+	}
+	m, err = Open(dir)
+	if err != nil {
+		t.Fatalf("Unable to read manifest: %s", err)
+	}
+	if !m.Recursive {
+		t.Fatalf("Recursive flag should be read from the manifest file")
+	}
+
+	var validate = func(expected bool, msg string) {
+		var ok, err = m.Validate()
+		if err != nil {
+			t.Fatalf("Unable to validate: %s", err)
+		}
+		if ok != expected {
+			t.Errorf("Validate() returned %v, want %v: %s", ok, expected, msg)
+		}
+	}
+	validate(true, "unchanged tree")
+
+	// Adding a hidden file changes its directory's mtime, but that shouldn't
+	// matter
+	err = os.WriteFile(filepath.Join(dir, "issue1", "page1", ".DS_Store"), nil, 0644)
+	if err != nil {
+		t.Fatalf("Unable to write hidden file: %s", err)
+	}
+	validate(true, "hidden file added")
+
+	err = os.Chmod(filepath.Join(dir, "issue1", "page2"), 0700)
+	if err != nil {
+		t.Fatalf("Unable to chmod: %s", err)
+	}
+	validate(false, "subdirectory mode changed")
+	os.Chmod(filepath.Join(dir, "issue1", "page2"), 0755)
+
+	var nested = filepath.Join(dir, "issue1", "page2", "b.txt")
+	var info, _ = os.Stat(nested)
+	err = os.WriteFile(nested, []byte("issue1/page2/b.tx!"), 0644)
+	if err == nil {
+		err = os.Chtimes(nested, info.ModTime(), info.ModTime())
+	}
+	if err != nil {
+		t.Fatalf("Unable to modify %q: %s", nested, err)
+	}
+	validate(false, "nested file's contents changed")
+}
+
+func TestBuildRecursiveSpecialFile(t *testing.T) {
+	var dir = t.TempDir()
+	var err = os.Symlink("nowhere", filepath.Join(dir, "link"))
+	if err != nil {
+		t.Fatalf("Unable to create symlink: %s", err)
+	}
+
+	_, err = BuildRecursive(dir, nil)
+	if err == nil {
+		t.Errorf("Recursive build should fail on special files")
+	}
+}
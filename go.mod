@@ -5,9 +5,13 @@ go 1.19
 require (
 	github.com/google/go-cmp v0.3.1
 	github.com/gorilla/sessions v1.1.3
+	github.com/zeebo/blake3 v0.2.4
+	golang.org/x/crypto v0.24.0
 )
 
 require (
 	github.com/gorilla/context v1.1.1 // indirect
 	github.com/gorilla/securecookie v1.1.1 // indirect
+	github.com/klauspost/cpuid/v2 v2.0.12 // indirect
+	golang.org/x/sys v0.21.0 // indirect
 )
@@ -0,0 +1,398 @@
+package hasher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/uoregon-libraries/gopkg/fileutil"
+)
+
+// A ChecksumEntry is a single line from a checksum list, such as those
+// written by sha256sum or md5sum
+type ChecksumEntry struct {
+	Path string // The file's path as listed, with "/" separators
+	Algo Algo
+	Sum  string // Lowercase hex digest
+}
+
+// ListFormat is a style of checksum list
+type ListFormat int
+
+// All checksum list formats we can write. Both are always accepted when
+// parsing.
+const (
+	// GNUFormat is the default output of GNU coreutils, e.g., sha256sum:
+	// "<digest>  <path>"
+	GNUFormat ListFormat = iota
+
+	// BSDFormat is the output of BSD tools and "sha256sum --tag":
+	// "SHA256 (<path>) = <digest>"
+	BSDFormat
+)
+
+// guessAlgos maps hex digest lengths to the algorithms we assume for GNU
+// format lines when we don't know the algorithm any other way. Only the
+// classic coreutils algorithms are guessed, as several newer ones share a
+// digest length with sha256.
+var guessAlgos = map[int]Algo{
+	32:  MD5,
+	40:  SHA1,
+	64:  SHA256,
+	128: SHA512,
+}
+
+// AlgoFromChecksumFilename returns the algorithm implied by a checksum
+// list's name, e.g., "delivery.sha256", "SHA256SUMS", or "md5sums.txt".
+// Only registered algorithms are returned; an empty Algo means the name
+// doesn't say.
+func AlgoFromChecksumFilename(name string) Algo {
+	var base = strings.ToLower(filepath.Base(name))
+	var candidates = []string{strings.TrimPrefix(filepath.Ext(base), ".")}
+	var stem = strings.TrimSuffix(base, filepath.Ext(base))
+	for _, s := range []string{base, stem} {
+		if strings.HasSuffix(s, "sums") {
+			candidates = append(candidates, strings.TrimSuffix(s, "sums"))
+		}
+	}
+
+	for _, c := range candidates {
+		if c != "" && New(Algo(c)) != nil {
+			return Algo(c)
+		}
+	}
+	return ""
+}
+
+// ParseChecksums reads a checksum list in either GNU or BSD format. BSD
+// lines name their algorithm; GNU lines use a, or if a is empty, an
+// algorithm guessed from the digest's length (md5, sha1, sha256, or sha512).
+//
+// GNU "binary mode" markers and escaped filenames are understood. Blank
+// lines and lines starting with "#" are skipped, but any other line which
+// can't be parsed is an error.
+func ParseChecksums(r io.Reader, a Algo) ([]*ChecksumEntry, error) {
+	var entries []*ChecksumEntry
+	var scanner = bufio.NewScanner(r)
+	var lineNum int
+	for scanner.Scan() {
+		lineNum++
+		var line = strings.TrimSuffix(scanner.Text(), "\r")
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var entry, err = parseChecksumLine(line, a)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+		entries = append(entries, entry)
+	}
+	if scanner.Err() != nil {
+		return nil, scanner.Err()
+	}
+	return entries, nil
+}
+
+func parseChecksumLine(line string, a Algo) (*ChecksumEntry, error) {
+	// A leading backslash means the filename has escaped backslashes and line
+	// breaks
+	var escaped = line[0] == '\\'
+	if escaped {
+		line = line[1:]
+	}
+
+	var entry, err = parseBSDLine(line)
+	if entry == nil && err == nil {
+		entry, err = parseGNULine(line, a)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if escaped {
+		entry.Path = unescapeChecksumPath(entry.Path)
+	}
+	if entry.Path == "" {
+		return nil, fmt.Errorf("missing file name")
+	}
+
+	var raw []byte
+	raw, err = hex.DecodeString(entry.Sum)
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest %q", entry.Sum)
+	}
+	entry.Sum = strings.ToLower(entry.Sum)
+
+	var h = New(entry.Algo)
+	if h == nil {
+		return nil, fmt.Errorf("unsupported algorithm %q", entry.Algo)
+	}
+	if len(raw) != h.Size() {
+		return nil, fmt.Errorf("invalid %s digest %q: wrong length", entry.Algo, entry.Sum)
+	}
+	return entry, nil
+}
+
+// parseBSDLine returns nil and no error if line isn't in the BSD format
+func parseBSDLine(line string) (*ChecksumEntry, error) {
+	var start = strings.Index(line, " (")
+	var end = strings.LastIndex(line, ") = ")
+	if start < 1 || end < start || strings.ContainsAny(line[:start], " \t") {
+		return nil, nil
+	}
+
+	var tag = strings.ToLower(line[:start])
+	if tag == "blake2b" {
+		tag = string(BLAKE2b_512)
+	}
+	return &ChecksumEntry{Algo: Algo(tag), Path: line[start+2 : end], Sum: line[end+4:]}, nil
+}
+
+func parseGNULine(line string, a Algo) (*ChecksumEntry, error) {
+	var i = strings.IndexByte(line, ' ')
+	if i < 1 || i == len(line)-1 {
+		return nil, fmt.Errorf("improperly formatted checksum line")
+	}
+
+	var entry = &ChecksumEntry{Algo: a, Sum: line[:i]}
+	entry.Path = line[i+1:]
+	// The separator is normally two characters: a space and a mode indicator
+	// (space for text, "*" for binary), but some tools only write one space
+	if entry.Path[0] == ' ' || entry.Path[0] == '*' {
+		entry.Path = entry.Path[1:]
+	}
+
+	if entry.Algo == "" {
+		entry.Algo = guessAlgos[len(entry.Sum)]
+		if entry.Algo == "" {
+			return nil, fmt.Errorf("unable to determine the algorithm for digest %q", entry.Sum)
+		}
+	}
+	return entry, nil
+}
+
+var checksumPathEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+func unescapeChecksumPath(p string) string {
+	var sb strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] != '\\' || i == len(p)-1 {
+			sb.WriteByte(p[i])
+			continue
+		}
+		i++
+		switch p[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		default:
+			sb.WriteByte(p[i])
+		}
+	}
+	return sb.String()
+}
+
+// CheckStatus is the outcome of verifying a single file
+type CheckStatus int
+
+// All possible file verification results
+const (
+	CheckOK      CheckStatus = iota + 1 // The file's digest matches the list
+	CheckFailed                         // The file's digest doesn't match, or the file couldn't be read
+	CheckMissing                        // The file doesn't exist
+)
+
+func (s CheckStatus) String() string {
+	switch s {
+	case CheckOK:
+		return "OK"
+	case CheckFailed:
+		return "FAILED"
+	case CheckMissing:
+		return "MISSING"
+	}
+	return fmt.Sprintf("unknown status (%d)", int(s))
+}
+
+// CheckResult describes the verification of one file from a checksum list
+type CheckResult struct {
+	Path     string // The file's path as listed
+	Status   CheckStatus
+	Expected string // The listed digest
+	Actual   string // The digest we computed, if the file could be read
+	Err      error  // Why the file couldn't be read, if it couldn't
+}
+
+// String returns the result in the style of "sha256sum -c"
+func (r *CheckResult) String() string {
+	if r.Status == CheckFailed && r.Err != nil {
+		return fmt.Sprintf("%s: %s (%s)", r.Path, r.Status, r.Err)
+	}
+	return fmt.Sprintf("%s: %s", r.Path, r.Status)
+}
+
+// VerifyChecksums hashes every file in entries and compares it to the
+// listed digest, returning a result for each entry in the same order.
+// Relative paths are resolved against dir.
+func VerifyChecksums(dir string, entries []*ChecksumEntry) []*CheckResult {
+	var hashers = make(map[Algo]*Hasher)
+	var results = make([]*CheckResult, len(entries))
+	for i, entry := range entries {
+		var r = &CheckResult{Path: entry.Path, Expected: entry.Sum}
+		results[i] = r
+
+		var h = hashers[entry.Algo]
+		if h == nil {
+			h = New(entry.Algo)
+			hashers[entry.Algo] = h
+		}
+		if h == nil {
+			r.Status = CheckFailed
+			r.Err = fmt.Errorf("unsupported algorithm %q", entry.Algo)
+			continue
+		}
+
+		var path = filepath.FromSlash(entry.Path)
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		var sum, err = h.FileSum(path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.Status = CheckMissing
+		case err != nil:
+			r.Status = CheckFailed
+			r.Err = err
+		case !strings.EqualFold(sum, entry.Sum):
+			r.Status = CheckFailed
+			r.Actual = sum
+		default:
+			r.Status = CheckOK
+			r.Actual = sum
+		}
+	}
+	return results
+}
+
+// VerifyChecksumFile reads the checksum list at filename and verifies every
+// file in it, relative to the list's directory. The algorithm for GNU format
+// lines is taken from the file's name if possible (see
+// AlgoFromChecksumFilename), otherwise guessed as described in
+// ParseChecksums.
+//
+// An error is only returned if the list itself can't be read or parsed;
+// problems with the listed files are reported in the results.
+func VerifyChecksumFile(filename string) ([]*CheckResult, error) {
+	var f, err = os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening %q: %w", filename, err)
+	}
+	defer f.Close()
+
+	var entries []*ChecksumEntry
+	entries, err = ParseChecksums(f, AlgoFromChecksumFilename(filename))
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", filename, err)
+	}
+	return VerifyChecksums(filepath.Dir(filename), entries), nil
+}
+
+// bsdTag returns the BSD-style tag coreutils uses for an algorithm
+func bsdTag(a Algo) string {
+	switch a {
+	case BLAKE2b_512:
+		return "BLAKE2b"
+	case BLAKE2b_256:
+		return "BLAKE2b-256"
+	}
+	return strings.ToUpper(string(a))
+}
+
+// WriteChecksums hashes every regular file under dir, writing a checksum
+// list to w in the given format. Paths are relative to dir and use "/"
+// separators, so the list can be verified from dir with, e.g., "sha256sum
+// -c". Files are listed in lexical order, and anything other than regular
+// files and directories is skipped.
+func WriteChecksums(w io.Writer, dir string, h *Hasher, format ListFormat) error {
+	return writeChecksums(w, dir, h, format, "")
+}
+
+// writeChecksums does the work for WriteChecksums, skipping the file at
+// skip, if given, so a list doesn't include itself
+func writeChecksums(w io.Writer, dir string, h *Hasher, format ListFormat, skip string) error {
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() || path == skip {
+			return nil
+		}
+
+		var rel string
+		rel, err = filepath.Rel(dir, path)
+		if err != nil {
+			return fmt.Errorf("cannot parse %q's relative file path: %s", path, err)
+		}
+		rel = filepath.ToSlash(rel)
+
+		var sum string
+		sum, err = h.FileSum(path)
+		if err != nil {
+			return err
+		}
+
+		var prefix string
+		var escaped = checksumPathEscaper.Replace(rel)
+		if escaped != rel {
+			prefix = `\`
+		}
+		switch format {
+		case GNUFormat:
+			_, err = fmt.Fprintf(w, "%s%s  %s\n", prefix, sum, escaped)
+		case BSDFormat:
+			_, err = fmt.Fprintf(w, "%s%s (%s) = %s\n", prefix, bsdTag(Algo(h.Name)), escaped, sum)
+		default:
+			return fmt.Errorf("invalid checksum list format %d", format)
+		}
+		return err
+	})
+}
+
+// WriteChecksumFile writes a checksum list for every regular file in
+// filename's directory tree (other than filename itself) to filename; see
+// WriteChecksums. filename is replaced if it exists, but only once every file
+// has been hashed, so a failure leaves any existing list alone.
+func WriteChecksumFile(filename string, h *Hasher, format ListFormat) error {
+	var abs, err = filepath.Abs(filename)
+	if err != nil {
+		return fmt.Errorf("unable to determine absolute path of %q: %s", filename, err)
+	}
+
+	var buf bytes.Buffer
+	err = writeChecksums(&buf, filepath.Dir(abs), h, format, abs)
+	if err != nil {
+		return fmt.Errorf("writing %q: %w", filename, err)
+	}
+
+	var f = fileutil.NewSafeFile(abs)
+	_, err = f.Write(buf.Bytes())
+	if err != nil {
+		f.Cancel()
+		return fmt.Errorf("writing %q: %w", filename, err)
+	}
+
+	err = f.Close()
+	if err != nil {
+		return fmt.Errorf("writing %q: %w", filename, err)
+	}
+	return nil
+}
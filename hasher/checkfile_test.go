@@ -0,0 +1,211 @@
+package hasher
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAlgoFromChecksumFilename(t *testing.T) {
+	var tests = map[string]Algo{
+		"delivery.sha256":   SHA256,
+		"SHA256SUMS":        SHA256,
+		"/path/to/MD5SUMS":  MD5,
+		"md5sums.txt":       MD5,
+		"files.sha3-256":    SHA3_256,
+		"checksums.txt":     "",
+		"bogussums":         "",
+		"SHA512SUMS.asc.gz": "",
+	}
+	for name, expected := range tests {
+		var got = AlgoFromChecksumFilename(name)
+		if got != expected {
+			t.Errorf("AlgoFromChecksumFilename(%q) = %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestParseChecksums(t *testing.T) {
+	var list = strings.Join([]string{
+		"# A comment",
+		"098f6bcd4621d373cade4e832627b4f6  test.txt",
+		"098F6BCD4621D373CADE4E832627B4F6 *binary mode.bin",
+		"098f6bcd4621d373cade4e832627b4f6 one space.txt",
+		`\098f6bcd4621d373cade4e832627b4f6  line\nbreak\\slash`,
+		"",
+		"SHA1 (sub/dir/bsd (1).txt) = a94a8fe5ccb19ba61c4c0873d391e987982fbbd3\r",
+		"BLAKE2b-256 (b2.txt) = 928b20366943e2afd11ebc0eae2e53a93bf177a4fcf35bcc64d503704e65e202",
+	}, "\n")
+
+	var entries, err = ParseChecksums(strings.NewReader(list), "")
+	if err != nil {
+		t.Fatalf("ParseChecksums() error: %s", err)
+	}
+
+	var expected = []ChecksumEntry{
+		{Path: "test.txt", Algo: MD5, Sum: "098f6bcd4621d373cade4e832627b4f6"},
+		{Path: "binary mode.bin", Algo: MD5, Sum: "098f6bcd4621d373cade4e832627b4f6"},
+		{Path: "one space.txt", Algo: MD5, Sum: "098f6bcd4621d373cade4e832627b4f6"},
+		{Path: "line\nbreak\\slash", Algo: MD5, Sum: "098f6bcd4621d373cade4e832627b4f6"},
+		{Path: "sub/dir/bsd (1).txt", Algo: SHA1, Sum: "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"},
+		{Path: "b2.txt", Algo: BLAKE2b_256, Sum: "928b20366943e2afd11ebc0eae2e53a93bf177a4fcf35bcc64d503704e65e202"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("ParseChecksums() returned %d entries, want %d", len(entries), len(expected))
+	}
+	for i, e := range expected {
+		if *entries[i] != e {
+			t.Errorf("entry %d = %#v, want %#v", i, *entries[i], e)
+		}
+	}
+
+	// With an explicit algorithm, a 64-character digest isn't assumed to be
+	// sha256
+	entries, err = ParseChecksums(strings.NewReader("928b20366943e2afd11ebc0eae2e53a93bf177a4fcf35bcc64d503704e65e202  b2.txt\n"), BLAKE2b_256)
+	if err != nil {
+		t.Fatalf("ParseChecksums() error: %s", err)
+	}
+	if entries[0].Algo != BLAKE2b_256 {
+		t.Errorf("Algo = %q, want %q", entries[0].Algo, BLAKE2b_256)
+	}
+
+	for _, bad := range []string{
+		"not a checksum line",
+		"098f6bcd4621d373cade4e832627b4f6",
+		"xyz  test.txt",
+		"0123  test.txt",
+		"BOGUS (test.txt) = 098f6bcd4621d373cade4e832627b4f6",
+		"SHA256 (test.txt) = 098f6bcd4621d373cade4e832627b4f6",
+	} {
+		_, err = ParseChecksums(strings.NewReader(bad), "")
+		if err == nil {
+			t.Errorf("ParseChecksums(%q) should be an error", bad)
+		}
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		var path = filepath.Join(dir, filepath.FromSlash(name))
+		var err = os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = ioutil.WriteFile(path, []byte(contents), 0644)
+		}
+		if err != nil {
+			t.Fatalf("Unable to write %q: %s", path, err)
+		}
+	}
+}
+
+func TestVerifyChecksumFile(t *testing.T) {
+	var dir = t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"good.txt":     "test",
+		"sub/bad.txt":  "not test",
+		"MD5SUMS":      "098f6bcd4621d373cade4e832627b4f6  good.txt\n098f6bcd4621d373cade4e832627b4f6  sub/bad.txt\n098f6bcd4621d373cade4e832627b4f6  missing.txt\n",
+		"invalid.sha1": "not a checksum line\n",
+	})
+
+	var results, err = VerifyChecksumFile(filepath.Join(dir, "MD5SUMS"))
+	if err != nil {
+		t.Fatalf("VerifyChecksumFile() error: %s", err)
+	}
+
+	var expected = []string{"good.txt: OK", "sub/bad.txt: FAILED", "missing.txt: MISSING"}
+	if len(results) != len(expected) {
+		t.Fatalf("VerifyChecksumFile() returned %d results, want %d", len(results), len(expected))
+	}
+	for i, s := range expected {
+		if results[i].String() != s {
+			t.Errorf("result %d = %q, want %q", i, results[i], s)
+		}
+	}
+	if results[1].Actual != NewMD5().Sum(strings.NewReader("not test")) {
+		t.Errorf("failed result's Actual = %q, want the file's checksum", results[1].Actual)
+	}
+
+	_, err = VerifyChecksumFile(filepath.Join(dir, "invalid.sha1"))
+	if err == nil {
+		t.Errorf("VerifyChecksumFile() should fail on an invalid list")
+	}
+	_, err = VerifyChecksumFile(filepath.Join(dir, "nope.sha1"))
+	if err == nil {
+		t.Errorf("VerifyChecksumFile() should fail on a missing list")
+	}
+}
+
+func TestWriteChecksums(t *testing.T) {
+	var dir = t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"b.txt":          "test",
+		"a/nested.txt":   "test",
+		"back\\slash":    "test",
+		"line\nbreak.md": "test",
+	})
+
+	var buf bytes.Buffer
+	var err = WriteChecksums(&buf, dir, NewMD5(), GNUFormat)
+	if err != nil {
+		t.Fatalf("WriteChecksums() error: %s", err)
+	}
+	var sum = "098f6bcd4621d373cade4e832627b4f6"
+	var expected = sum + "  a/nested.txt\n" +
+		sum + "  b.txt\n" +
+		"\\" + sum + "  back\\\\slash\n" +
+		"\\" + sum + "  line\\nbreak.md\n"
+	if buf.String() != expected {
+		t.Errorf("WriteChecksums() wrote %q, want %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	err = WriteChecksums(&buf, dir, NewMD5(), BSDFormat)
+	if err != nil {
+		t.Fatalf("WriteChecksums() error: %s", err)
+	}
+	if !strings.HasPrefix(buf.String(), "MD5 (a/nested.txt) = "+sum+"\n") {
+		t.Errorf("WriteChecksums() BSD format wrote %q", buf.String())
+	}
+
+	// A list written to the directory shouldn't include itself, and should
+	// verify cleanly in either format
+	for _, format := range []ListFormat{GNUFormat, BSDFormat} {
+		var listFile = filepath.Join(dir, "SHA256SUMS")
+		err = WriteChecksumFile(listFile, NewSHA256(), format)
+		if err != nil {
+			t.Fatalf("WriteChecksumFile() error: %s", err)
+		}
+
+		var results []*CheckResult
+		results, err = VerifyChecksumFile(listFile)
+		if err != nil {
+			t.Fatalf("VerifyChecksumFile() error: %s", err)
+		}
+		if len(results) != 4 {
+			t.Fatalf("VerifyChecksumFile() returned %d results, want 4", len(results))
+		}
+		for _, r := range results {
+			if r.Status != CheckOK {
+				t.Errorf("%s", r)
+			}
+		}
+	}
+
+	// A failed write mustn't destroy the existing list
+	var listFile = filepath.Join(dir, "SHA256SUMS")
+	var before, _ = ioutil.ReadFile(listFile)
+	err = WriteChecksumFile(listFile, NewSHA256(), ListFormat(99))
+	if err == nil {
+		t.Fatalf("WriteChecksumFile() with an invalid format should fail")
+	}
+	var after []byte
+	after, err = ioutil.ReadFile(listFile)
+	if err != nil {
+		t.Fatalf("Existing list should survive a failed write: %s", err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Errorf("Existing list was changed by a failed write: %q", after)
+	}
+}
@@ -0,0 +1,225 @@
+package hasher
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Encoding is a way of representing a digest as a string
+type Encoding int
+
+// All digest encodings we support
+const (
+	// Hex is lowercase hexadecimal, as returned by Hasher.Sum and used in
+	// most checksum files. Parsing is case-insensitive.
+	Hex Encoding = iota
+
+	// Base64 is standard, padded base64, as used in Content-MD5 and HTTP
+	// Digest headers and by most cloud object stores
+	Base64
+
+	// Base64URL is URL-safe base64 without padding
+	Base64URL
+
+	// Multihash is a self-describing multihash (algorithm code, digest
+	// length, and digest) in base58btc, the form IPFS uses. Only algorithms
+	// with a multihash code can be encoded this way.
+	Multihash
+)
+
+func (e Encoding) String() string {
+	switch e {
+	case Hex:
+		return "hex"
+	case Base64:
+		return "base64"
+	case Base64URL:
+		return "base64url"
+	case Multihash:
+		return "multihash"
+	}
+	return fmt.Sprintf("unknown encoding (%d)", int(e))
+}
+
+// multihashCodes maps our algorithms to their codes in the multicodec table
+var multihashCodes = map[Algo]uint64{
+	MD5:         0xd5,
+	SHA1:        0x11,
+	SHA256:      0x12,
+	SHA512:      0x13,
+	SHA3_512:    0x14,
+	SHA3_256:    0x16,
+	BLAKE3:      0x1e,
+	BLAKE2b_256: 0xb220,
+	BLAKE2b_512: 0xb240,
+}
+
+// A Digest is the raw output of a hash algorithm, which can be encoded in
+// various ways
+type Digest struct {
+	Algo Algo
+	Sum  []byte
+}
+
+// Digest resets Hasher's state and returns the digest of the given
+// io.Reader, or an error if r can't be read to the end
+func (h *Hasher) Digest(r io.Reader) (Digest, error) {
+	h.Hash.Reset()
+	var _, err = io.Copy(h.Hash, r)
+	if err != nil {
+		return Digest{}, err
+	}
+	return Digest{Algo: Algo(h.Name), Sum: h.Hash.Sum(nil)}, nil
+}
+
+// String returns the digest in hex
+func (d Digest) String() string {
+	return hex.EncodeToString(d.Sum)
+}
+
+// Equal returns true if d and d2 are for the same algorithm and have the
+// same value, regardless of how they were originally encoded
+func (d Digest) Equal(d2 Digest) bool {
+	return d.Algo == d2.Algo && bytes.Equal(d.Sum, d2.Sum)
+}
+
+// Encode returns the digest as a string in the given encoding. An error is
+// only possible for the Multihash encoding, when d's algorithm has no
+// multihash code, or for an invalid encoding.
+func (d Digest) Encode(e Encoding) (string, error) {
+	switch e {
+	case Hex:
+		return hex.EncodeToString(d.Sum), nil
+	case Base64:
+		return base64.StdEncoding.EncodeToString(d.Sum), nil
+	case Base64URL:
+		return base64.RawURLEncoding.EncodeToString(d.Sum), nil
+	case Multihash:
+		var code, ok = multihashCodes[d.Algo]
+		if !ok {
+			return "", fmt.Errorf("algorithm %q has no multihash code", d.Algo)
+		}
+		var data = binary.AppendUvarint(nil, code)
+		data = binary.AppendUvarint(data, uint64(len(d.Sum)))
+		return base58Encode(append(data, d.Sum...)), nil
+	}
+	return "", fmt.Errorf("invalid encoding %s", e)
+}
+
+// ParseDigest decodes s, a digest for algorithm a in the given encoding.
+// Base64 and Base64URL digests are accepted with or without padding. For
+// Multihash, a may be empty, as the algorithm is part of the multihash; if
+// it's given and doesn't match, that's an error.
+//
+// If the algorithm is registered, the digest's length is checked.
+func ParseDigest(a Algo, s string, e Encoding) (Digest, error) {
+	var d = Digest{Algo: a}
+	var err error
+	switch e {
+	case Hex:
+		d.Sum, err = hex.DecodeString(s)
+	case Base64:
+		d.Sum, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(s, "="))
+	case Base64URL:
+		d.Sum, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	case Multihash:
+		d, err = parseMultihash(a, s)
+	default:
+		return Digest{}, fmt.Errorf("invalid encoding %s", e)
+	}
+	if err != nil {
+		return Digest{}, fmt.Errorf("invalid %s digest %q: %w", e, s, err)
+	}
+
+	var h = New(d.Algo)
+	if h != nil && h.Size() != len(d.Sum) {
+		return Digest{}, fmt.Errorf("invalid %s digest %q: %s digests are %d bytes, not %d", e, s, d.Algo, h.Size(), len(d.Sum))
+	}
+	return d, nil
+}
+
+func parseMultihash(a Algo, s string) (Digest, error) {
+	var data, err = base58Decode(s)
+	if err != nil {
+		return Digest{}, err
+	}
+
+	var code, n = binary.Uvarint(data)
+	if n <= 0 {
+		return Digest{}, fmt.Errorf("invalid algorithm code")
+	}
+	data = data[n:]
+	var length uint64
+	length, n = binary.Uvarint(data)
+	if n <= 0 {
+		return Digest{}, fmt.Errorf("invalid digest length")
+	}
+	data = data[n:]
+	if uint64(len(data)) != length {
+		return Digest{}, fmt.Errorf("digest length should be %d, but is %d", length, len(data))
+	}
+
+	var d = Digest{Sum: data}
+	for algo, c := range multihashCodes {
+		if c == code {
+			d.Algo = algo
+		}
+	}
+	if d.Algo == "" {
+		return Digest{}, fmt.Errorf("unsupported multihash code 0x%x", code)
+	}
+	if a != "" && a != d.Algo {
+		return Digest{}, fmt.Errorf("digest is %s, not %s", d.Algo, a)
+	}
+	return d, nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// base58Encode encodes data using the Bitcoin alphabet, with each leading
+// zero byte represented as a leading "1"
+func base58Encode(data []byte) string {
+	var n = new(big.Int).SetBytes(data)
+	var radix = big.NewInt(58)
+	var mod = new(big.Int)
+	var out []byte
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range data {
+		if b != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return string(out)
+}
+
+func base58Decode(s string) ([]byte, error) {
+	var n = new(big.Int)
+	var radix = big.NewInt(58)
+	var zeros int
+	for i, c := range s {
+		var val = strings.IndexRune(base58Alphabet, c)
+		if val < 0 {
+			return nil, fmt.Errorf("invalid base58 character %q", c)
+		}
+		if val == 0 && i == zeros {
+			zeros++
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(val)))
+	}
+	return append(make([]byte, zeros), n.Bytes()...), nil
+}
@@ -0,0 +1,108 @@
+package hasher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDigestEncode(t *testing.T) {
+	var tests = []struct {
+		name     string
+		hasher   *Hasher
+		encoding Encoding
+		expected string
+	}{
+		{"SHA256 hex", NewSHA256(), Hex, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
+		{"SHA256 base64", NewSHA256(), Base64, "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg="},
+		{"SHA256 base64url", NewSHA256(), Base64URL, "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg"},
+		{"SHA256 multihash", NewSHA256(), Multihash, "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w"},
+		{"MD5 base64", NewMD5(), Base64, "CY9rzUYh03PK3k6DJie09g=="},
+		{"BLAKE2b-256 multihash", New(BLAKE2b_256), Multihash, "2DrjgbE7wHaMmNxiZd3mqWXnCkjn9cLUiYHTJZ6hxAJg82nQgy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d, err = tt.hasher.Digest(strings.NewReader("test"))
+			if err != nil {
+				t.Fatalf("Digest() error: %s", err)
+			}
+			var got string
+			got, err = d.Encode(tt.encoding)
+			if err != nil {
+				t.Fatalf("Encode() error: %s", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Encode() = %v, want %v", got, tt.expected)
+			}
+
+			var parsed Digest
+			parsed, err = ParseDigest(Algo(tt.hasher.Name), got, tt.encoding)
+			if err != nil {
+				t.Fatalf("ParseDigest() error: %s", err)
+			}
+			if !parsed.Equal(d) {
+				t.Errorf("ParseDigest() = %s, want %s", parsed, d)
+			}
+		})
+	}
+}
+
+func TestDigestMultihashUnsupported(t *testing.T) {
+	var d, err = New(CRC32C).Digest(strings.NewReader("test"))
+	if err != nil {
+		t.Fatalf("Digest() error: %s", err)
+	}
+	_, err = d.Encode(Multihash)
+	if err == nil {
+		t.Errorf("Encode(Multihash) should fail for an algorithm without a multihash code")
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	var expected, _ = NewSHA256().Digest(strings.NewReader("test"))
+
+	var tests = []struct {
+		name     string
+		algo     Algo
+		input    string
+		encoding Encoding
+		wantErr  bool
+	}{
+		{"uppercase hex", SHA256, "9F86D081884C7D659A2FEAA0C55AD015A3BF4F1B2B0B822CD15D6C15B0F00A08", Hex, false},
+		{"unpadded base64", SHA256, "n4bQgYhMfWWaL+qgxVrQFaO/TxsrC4Is0V1sFbDwCgg", Base64, false},
+		{"padded base64url", SHA256, "n4bQgYhMfWWaL-qgxVrQFaO_TxsrC4Is0V1sFbDwCgg=", Base64URL, false},
+		{"multihash without algo", "", "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w", Multihash, false},
+		{"invalid hex", SHA256, "not hex", Hex, true},
+		{"wrong length", SHA256, "098f6bcd4621d373cade4e832627b4f6", Hex, true},
+		{"multihash for wrong algo", MD5, "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6w", Multihash, true},
+		{"invalid base58", SHA256, "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH60", Multihash, true},
+		{"truncated multihash", SHA256, "QmZ5NmGeStdit7tV6gdak1F8FyZhPsfA843YS9f2ywKH6", Multihash, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = ParseDigest(tt.algo, tt.input, tt.encoding)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDigest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && !got.Equal(expected) {
+				t.Errorf("ParseDigest() = %s, want %s", got, expected)
+			}
+		})
+	}
+}
+
+func TestBase58(t *testing.T) {
+	var data = []byte{0, 0, 1, 2}
+	var encoded = base58Encode(data)
+	if encoded != "115T" {
+		t.Errorf("base58Encode() = %v, want %v", encoded, "115T")
+	}
+	var decoded, err = base58Decode(encoded)
+	if err != nil {
+		t.Fatalf("base58Decode() error: %s", err)
+	}
+	if string(decoded) != string(data) {
+		t.Errorf("base58Decode() = %v, want %v", decoded, data)
+	}
+}
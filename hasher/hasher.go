@@ -7,33 +7,97 @@ import (
 	"crypto/sha512"
 	"fmt"
 	"hash"
+	"hash/crc32"
 	"io"
 	"os"
+	"sort"
+	"sync"
+
+	"github.com/zeebo/blake3"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
 )
 
 // Hasher wraps any hash.Hash implementation with some shortcuts to help with
-// common use-cases we have
+// common use-cases we have.
+//
+// A Hasher is not safe for concurrent use: Sum and FileSum reset the
+// underlying hash, so two goroutines sharing a Hasher will corrupt each
+// other's results. Use Clone to give each goroutine its own.
 type Hasher struct {
 	hash.Hash
 	Name string
+
+	newHash func() hash.Hash
 }
 
 // Algo is our enum-like value for supported algorithms we use widely
 type Algo string
 
-// The algorithms we support currently
+// The algorithms we support out of the box. Others can be added with
+// Register.
 const (
 	MD5    Algo = "md5"
 	SHA1        = "sha1"
 	SHA256      = "sha256"
 	SHA512      = "sha512"
+
+	SHA3_256    Algo = "sha3-256"
+	SHA3_512    Algo = "sha3-512"
+	BLAKE2b_256 Algo = "blake2b-256"
+	BLAKE2b_512 Algo = "blake2b-512"
+	BLAKE3      Algo = "blake3" // BLAKE3 with the standard 256-bit digest
+	CRC32C      Algo = "crc32c" // CRC-32 using the Castagnoli polynomial; detects corruption, but is not a secure hash
 )
 
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var fnLock sync.RWMutex
 var fnLookup = map[Algo]func() hash.Hash{
-	MD5:    md5.New,
-	SHA1:   sha1.New,
-	SHA256: sha256.New,
-	SHA512: sha512.New,
+	MD5:         md5.New,
+	SHA1:        sha1.New,
+	SHA256:      sha256.New,
+	SHA512:      sha512.New,
+	SHA3_256:    sha3.New256,
+	SHA3_512:    sha3.New512,
+	BLAKE2b_256: func() hash.Hash { var h, _ = blake2b.New256(nil); return h },
+	BLAKE2b_512: func() hash.Hash { var h, _ = blake2b.New512(nil); return h },
+	BLAKE3:      func() hash.Hash { return blake3.New() },
+	CRC32C:      func() hash.Hash { return crc32.New(castagnoli) },
+}
+
+// Register makes a new algorithm available to New and FromString, and
+// therefore to anything which looks up hashers by name, such as bagit.Open
+// and the manifest package. It's meant to be called from an init function or
+// early in main.
+//
+// Register panics if fn is nil, the name is empty, or the algorithm is
+// already registered.
+func Register(a Algo, fn func() hash.Hash) {
+	if a == "" || fn == nil {
+		panic("hasher: Register requires a name and a hash function")
+	}
+
+	fnLock.Lock()
+	defer fnLock.Unlock()
+	var _, exists = fnLookup[a]
+	if exists {
+		panic(fmt.Sprintf("hasher: algorithm %q is already registered", a))
+	}
+	fnLookup[a] = fn
+}
+
+// Algos returns every registered algorithm, sorted by name
+func Algos() []Algo {
+	fnLock.RLock()
+	defer fnLock.RUnlock()
+
+	var list []Algo
+	for a := range fnLookup {
+		list = append(list, a)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i] < list[j] })
+	return list
 }
 
 // NewMD5 returns a Hasher using crypto/md5
@@ -59,11 +123,13 @@ func NewSHA512() *Hasher {
 // New returns a Hasher for the given algorithm. If you pass in an invalid
 // Algo, this will give you a nil Hasher.
 func New(a Algo) *Hasher {
+	fnLock.RLock()
 	var fn, ok = fnLookup[a]
+	fnLock.RUnlock()
 	if !ok {
 		return nil
 	}
-	return &Hasher{Name: string(a), Hash: fn()}
+	return &Hasher{Name: string(a), Hash: fn(), newHash: fn}
 }
 
 // FromString simplifies the case of just wanting to get a Hasher directly from
@@ -74,7 +140,25 @@ func FromString(a string) *Hasher {
 	return New(Algo(a))
 }
 
-// Sum resets Hasher's state and generates a hex sum of the given io.Reader
+// Clone returns a new Hasher using the same algorithm as h, with its own
+// independent state; h's state isn't copied. This is how to get a Hasher for
+// each goroutine when hashing in parallel.
+//
+// Hashers not created by this package (e.g., a Hasher literal wrapping a
+// custom hash.Hash) are cloned by looking up their Name, so Clone returns nil
+// if the name isn't a registered algorithm.
+func (h *Hasher) Clone() *Hasher {
+	if h.newHash == nil {
+		return FromString(h.Name)
+	}
+	return &Hasher{Name: h.Name, Hash: h.newHash(), newHash: h.newHash}
+}
+
+// Sum resets Hasher's state and generates a hex sum of the given io.Reader.
+//
+// Any error reading from r is ignored, so a failed read gives back the sum of
+// whatever was read before the failure. Use Checksum unless r can't fail
+// (e.g., a strings.Reader).
 func (h *Hasher) Sum(r io.Reader) string {
 	h.Hash.Reset()
 	io.Copy(h.Hash, r)
@@ -82,6 +166,19 @@ func (h *Hasher) Sum(r io.Reader) string {
 	return fmt.Sprintf("%x", data)
 }
 
+// Checksum resets Hasher's state and generates a hex sum of the given
+// io.Reader. If r can't be read to the end, an empty string and the read
+// error are returned.
+func (h *Hasher) Checksum(r io.Reader) (string, error) {
+	h.Hash.Reset()
+	var _, err = io.Copy(h.Hash, r)
+	if err != nil {
+		return "", err
+	}
+	var data = h.Hash.Sum(nil)
+	return fmt.Sprintf("%x", data), nil
+}
+
 // FileSum resets Hasher's state and generates a hex sum of the given file
 func (h *Hasher) FileSum(filename string) (string, error) {
 	var f, err = os.Open(filename)
@@ -89,5 +186,11 @@ func (h *Hasher) FileSum(filename string) (string, error) {
 		return "", fmt.Errorf("opening %q: %w", filename, err)
 	}
 	defer f.Close()
-	return h.Sum(f), nil
+
+	var sum string
+	sum, err = h.Checksum(f)
+	if err != nil {
+		return "", fmt.Errorf("reading %q: %w", filename, err)
+	}
+	return sum, nil
 }
@@ -1,6 +1,9 @@
 package hasher
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"hash"
 	"strings"
 	"testing"
 )
@@ -16,6 +19,13 @@ func TestSum(t *testing.T) {
 		{"SHA1", NewSHA1(), "test", "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3"},
 		{"SHA256", NewSHA256(), "test", "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"},
 		{"SHA512", NewSHA512(), "test", "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff"},
+		{"SHA3-256", New(SHA3_256), "test", "36f028580bb02cc8272a9a020f4200e346e276ae664e45ee80745574e2f5ab80"},
+		{"SHA3-512", New(SHA3_512), "test", "9ece086e9bac491fac5c1d1046ca11d737b92a2b2ebd93f005d7b710110c0a678288166e7fbe796883a4f2e9b3ca9f484f521d0ce464345cc1aec96779149c14"},
+		{"BLAKE2b-256", New(BLAKE2b_256), "test", "928b20366943e2afd11ebc0eae2e53a93bf177a4fcf35bcc64d503704e65e202"},
+		{"BLAKE2b-512", New(BLAKE2b_512), "test", "a71079d42853dea26e453004338670a53814b78137ffbed07603a41d76a483aa9bc33b582f77d30a65e6f29a896c0411f38312e1d66e0bf16386c86a89bea572"},
+		{"BLAKE3", New(BLAKE3), "test", "4878ca0425c739fa427f7eda20fe845f6b2e46ba5fe2a14df5b1e32f50603215"},
+		{"BLAKE3 empty", New(BLAKE3), "", "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262"},
+		{"CRC32C", New(CRC32C), "test", "86a072c0"},
 	}
 
 	for _, tt := range tests {
@@ -76,3 +86,60 @@ func TestFileSum(t *testing.T) {
 		})
 	}
 }
+
+func TestBLAKE3MultiChunk(t *testing.T) {
+	// BLAKE3's official test vector input: bytes counting 0-250, repeated. At
+	// 5121 bytes it spans six 1024-byte chunks.
+	var input = make([]byte, 5121)
+	for i := range input {
+		input[i] = byte(i % 251)
+	}
+	var expected = "628bd2cb2004694adaab7bbd778a25df25c47b9d4155a55f8fbd79f2fe154cff"
+
+	var got = New(BLAKE3).Sum(bytes.NewReader(input))
+	if got != expected {
+		t.Errorf("Sum() = %v, want %v", got, expected)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var a = Algo("test-sha256")
+	if FromString(string(a)) != nil {
+		t.Fatalf("FromString() returned a hasher for an unregistered algorithm")
+	}
+
+	Register(a, sha256.New)
+	var h = FromString(string(a))
+	if h == nil {
+		t.Fatalf("FromString() returned nil for a registered algorithm")
+	}
+	if h.Name != string(a) {
+		t.Errorf("Name = %v, want %v", h.Name, a)
+	}
+	var got = h.Sum(strings.NewReader("test"))
+	var expected = NewSHA256().Sum(strings.NewReader("test"))
+	if got != expected {
+		t.Errorf("Sum() = %v, want %v", got, expected)
+	}
+
+	var found bool
+	for _, algo := range Algos() {
+		if algo == a {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Algos() doesn't include %q", a)
+	}
+
+	for _, algo := range []Algo{a, MD5} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) didn't panic on a duplicate registration", algo)
+				}
+			}()
+			Register(algo, func() hash.Hash { return sha256.New() })
+		}()
+	}
+}
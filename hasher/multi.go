@@ -0,0 +1,97 @@
+package hasher
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// MultiHasher computes digests for several Hashers in a single pass over
+// the data, so getting, e.g., md5 and sha256 sums of a file only requires
+// reading it once.
+//
+// Like Hasher, a MultiHasher is not safe for concurrent use; use Clone to get
+// an independent MultiHasher for each goroutine.
+type MultiHasher struct {
+	hashers []*Hasher
+	w       io.Writer
+}
+
+// NewMulti returns a MultiHasher wrapping the given hashers. The hashers are
+// used directly, not cloned, so they shouldn't be used elsewhere while the
+// MultiHasher is in use. Each hasher should have a unique Name, as digests
+// are keyed by name.
+func NewMulti(hashers ...*Hasher) *MultiHasher {
+	var writers = make([]io.Writer, len(hashers))
+	for i, h := range hashers {
+		writers[i] = h.Hash
+	}
+	return &MultiHasher{hashers: hashers, w: io.MultiWriter(writers...)}
+}
+
+// Hashers returns the list of hashers m wraps
+func (m *MultiHasher) Hashers() []*Hasher {
+	return m.hashers
+}
+
+// Clone returns a new MultiHasher with a clone of each of m's hashers; see
+// Hasher.Clone. nil is returned if any hasher can't be cloned.
+func (m *MultiHasher) Clone() *MultiHasher {
+	var clones = make([]*Hasher, len(m.hashers))
+	for i, h := range m.hashers {
+		clones[i] = h.Clone()
+		if clones[i] == nil {
+			return nil
+		}
+	}
+	return NewMulti(clones...)
+}
+
+// Write implements io.Writer, adding p to every hasher's data
+func (m *MultiHasher) Write(p []byte) (int, error) {
+	return m.w.Write(p)
+}
+
+// Reset resets all hashers' states
+func (m *MultiHasher) Reset() {
+	for _, h := range m.hashers {
+		h.Hash.Reset()
+	}
+}
+
+// Sums returns the hex digest of every hasher, keyed by the hasher's name,
+// for all data written since the last Reset
+func (m *MultiHasher) Sums() map[string]string {
+	var sums = make(map[string]string, len(m.hashers))
+	for _, h := range m.hashers {
+		sums[h.Name] = fmt.Sprintf("%x", h.Hash.Sum(nil))
+	}
+	return sums
+}
+
+// Sum resets all hashers and returns the hex digests of everything read from
+// r, keyed by hasher name. r is only read once.
+func (m *MultiHasher) Sum(r io.Reader) (map[string]string, error) {
+	m.Reset()
+	var _, err = io.Copy(m.w, r)
+	if err != nil {
+		return nil, err
+	}
+	return m.Sums(), nil
+}
+
+// FileSum is Sum for the given file
+func (m *MultiHasher) FileSum(filename string) (map[string]string, error) {
+	var f, err = os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("opening %q: %w", filename, err)
+	}
+	defer f.Close()
+
+	var sums map[string]string
+	sums, err = m.Sum(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %q: %w", filename, err)
+	}
+	return sums, nil
+}
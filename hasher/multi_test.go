@@ -0,0 +1,100 @@
+package hasher
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestClone(t *testing.T) {
+	var h = NewSHA256()
+	h.Write([]byte("some state which shouldn't be copied"))
+
+	var c = h.Clone()
+	if c.Name != h.Name {
+		t.Errorf("Clone().Name = %v, want %v", c.Name, h.Name)
+	}
+	if c.Hash == h.Hash {
+		t.Fatalf("Clone() shares its hash.Hash with the original")
+	}
+	var expected = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	var got = c.Sum(strings.NewReader("test"))
+	if got != expected {
+		t.Errorf("Clone().Sum() = %v, want %v", got, expected)
+	}
+
+	// A Hasher built by hand is cloned by name
+	var literal = &Hasher{Hash: NewMD5().Hash, Name: "md5"}
+	if literal.Clone() == nil {
+		t.Errorf("Clone() of a hasher literal with a valid name returned nil")
+	}
+	literal.Name = "bogus"
+	if literal.Clone() != nil {
+		t.Errorf("Clone() of a hasher literal with an invalid name should return nil")
+	}
+}
+
+func TestCloneConcurrent(t *testing.T) {
+	var h = NewSHA256()
+	var expected = h.Sum(strings.NewReader(strings.Repeat("test", 10000)))
+
+	var wg sync.WaitGroup
+	var results = make([]string, 8)
+	for i := range results {
+		var c = h.Clone()
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				results[i] = c.Sum(strings.NewReader(strings.Repeat("test", 10000)))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for i, got := range results {
+		if got != expected {
+			t.Errorf("goroutine %d: Sum() = %v, want %v", i, got, expected)
+		}
+	}
+}
+
+func TestMultiHasher(t *testing.T) {
+	var m = NewMulti(NewMD5(), NewSHA1(), NewSHA256())
+	var expected = map[string]string{
+		"md5":    "098f6bcd4621d373cade4e832627b4f6",
+		"sha1":   "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3",
+		"sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+	}
+
+	for _, mh := range []*MultiHasher{m, m, m.Clone()} {
+		var sums, err = mh.Sum(strings.NewReader("test"))
+		if err != nil {
+			t.Fatalf("Sum() error: %s", err)
+		}
+		if len(sums) != len(expected) {
+			t.Errorf("Sum() returned %d sums, want %d", len(sums), len(expected))
+		}
+		for name, sum := range expected {
+			if sums[name] != sum {
+				t.Errorf("Sum()[%q] = %v, want %v", name, sums[name], sum)
+			}
+		}
+	}
+
+	var sums, err = m.FileSum("testdata/test.txt")
+	if err != nil {
+		t.Fatalf("FileSum() error: %s", err)
+	}
+	if sums["md5"] != "2490a3d39b0004e4afeb517ef0ddbe2d" {
+		t.Errorf(`FileSum()["md5"] = %v, want %v`, sums["md5"], "2490a3d39b0004e4afeb517ef0ddbe2d")
+	}
+	if sums["sha256"] != "3cd203ac11340842055a6de561c9d69ca4493e912bd4c3c440c80711e16d5aee" {
+		t.Errorf(`FileSum()["sha256"] = %v, want %v`, sums["sha256"], "3cd203ac11340842055a6de561c9d69ca4493e912bd4c3c440c80711e16d5aee")
+	}
+
+	_, err = m.FileSum("testdata/missing.txt")
+	if err == nil {
+		t.Errorf("FileSum() of a missing file should be an error")
+	}
+}
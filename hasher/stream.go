@@ -0,0 +1,100 @@
+package hasher
+
+import (
+	"fmt"
+	"io"
+)
+
+// Reader wraps an io.Reader, hashing and counting all data read through it.
+// This lets a file be checksummed as a side effect of some other operation,
+// such as an upload, without reading it a second time.
+type Reader struct {
+	r   io.Reader
+	h   *Hasher
+	n   int64
+	eof bool
+}
+
+// NewReader returns a Reader which reads from r, feeding all data read to h.
+// h is reset first, and shouldn't be used elsewhere until the Reader is done.
+func NewReader(r io.Reader, h *Hasher) *Reader {
+	h.Hash.Reset()
+	return &Reader{r: r, h: h}
+}
+
+// Read implements io.Reader
+func (r *Reader) Read(p []byte) (int, error) {
+	var n, err = r.r.Read(p)
+	if n > 0 {
+		r.h.Hash.Write(p[:n])
+		r.n += int64(n)
+	}
+	if err == io.EOF {
+		r.eof = true
+	}
+	return n, err
+}
+
+// N returns the number of bytes read so far
+func (r *Reader) N() int64 {
+	return r.n
+}
+
+// EOF returns true once the underlying reader has returned io.EOF, meaning
+// Sum and N describe the full stream
+func (r *Reader) EOF() bool {
+	return r.eof
+}
+
+// Sum returns the hex digest of all data read so far. It can be called at any
+// time, but is only the digest of the full stream once EOF returns true.
+func (r *Reader) Sum() string {
+	return fmt.Sprintf("%x", r.h.Hash.Sum(nil))
+}
+
+// Digest returns the digest of all data read so far; see Sum
+func (r *Reader) Digest() Digest {
+	return Digest{Algo: Algo(r.h.Name), Sum: r.h.Hash.Sum(nil)}
+}
+
+// Writer wraps an io.Writer, hashing and counting all data written through
+// it. Only bytes the underlying writer accepts are hashed, so after a short
+// write, Sum and N still describe exactly what was written.
+type Writer struct {
+	w io.Writer
+	h *Hasher
+	n int64
+}
+
+// NewWriter returns a Writer which writes to w, feeding all data written to
+// h. h is reset first, and shouldn't be used elsewhere until the Writer is
+// done.
+func NewWriter(w io.Writer, h *Hasher) *Writer {
+	h.Hash.Reset()
+	return &Writer{w: w, h: h}
+}
+
+// Write implements io.Writer
+func (w *Writer) Write(p []byte) (int, error) {
+	var n, err = w.w.Write(p)
+	if n > 0 {
+		w.h.Hash.Write(p[:n])
+		w.n += int64(n)
+	}
+	return n, err
+}
+
+// N returns the number of bytes written so far
+func (w *Writer) N() int64 {
+	return w.n
+}
+
+// Sum returns the hex digest of all data written so far
+func (w *Writer) Sum() string {
+	return fmt.Sprintf("%x", w.h.Hash.Sum(nil))
+}
+
+// Digest returns the digest of all data written so far
+func (w *Writer) Digest() Digest {
+	return Digest{Algo: Algo(w.h.Name), Sum: w.h.Hash.Sum(nil)}
+}
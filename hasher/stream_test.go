@@ -0,0 +1,130 @@
+package hasher
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+var errTest = errors.New("test error")
+
+// failingReader returns its data, then errTest rather than io.EOF
+type failingReader struct {
+	r io.Reader
+}
+
+func (f *failingReader) Read(p []byte) (int, error) {
+	var n, err = f.r.Read(p)
+	if err == io.EOF {
+		err = errTest
+	}
+	return n, err
+}
+
+// shortWriter accepts at most limit bytes
+type shortWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (s *shortWriter) Write(p []byte) (int, error) {
+	var room = s.limit - s.buf.Len()
+	if len(p) > room {
+		s.buf.Write(p[:room])
+		return room, io.ErrShortWrite
+	}
+	return s.buf.Write(p)
+}
+
+func TestChecksum(t *testing.T) {
+	var h = NewSHA256()
+	var got, err = h.Checksum(strings.NewReader("test"))
+	if err != nil {
+		t.Fatalf("Checksum() error: %s", err)
+	}
+	var expected = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if got != expected {
+		t.Errorf("Checksum() = %v, want %v", got, expected)
+	}
+
+	got, err = h.Checksum(&failingReader{strings.NewReader("test")})
+	if err != errTest {
+		t.Errorf("Checksum() error = %v, want %v", err, errTest)
+	}
+	if got != "" {
+		t.Errorf("Checksum() = %v after a read error, want an empty string", got)
+	}
+}
+
+func TestReader(t *testing.T) {
+	var r = NewReader(strings.NewReader("test"), NewSHA256())
+	if r.EOF() {
+		t.Errorf("EOF() should be false before reading")
+	}
+
+	var data, err = ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll() error: %s", err)
+	}
+	if string(data) != "test" {
+		t.Errorf("ReadAll() = %q, want %q", data, "test")
+	}
+	if !r.EOF() {
+		t.Errorf("EOF() should be true after reading everything")
+	}
+	if r.N() != 4 {
+		t.Errorf("N() = %d, want 4", r.N())
+	}
+	var expected = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	if r.Sum() != expected {
+		t.Errorf("Sum() = %v, want %v", r.Sum(), expected)
+	}
+	if r.Digest().String() != expected {
+		t.Errorf("Digest() = %v, want %v", r.Digest(), expected)
+	}
+
+	r = NewReader(&failingReader{strings.NewReader("test")}, NewSHA256())
+	_, err = ioutil.ReadAll(r)
+	if err != errTest {
+		t.Errorf("ReadAll() error = %v, want %v", err, errTest)
+	}
+	if r.EOF() {
+		t.Errorf("EOF() should be false after a read error")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	var w = NewWriter(&buf, NewMD5())
+	var _, err = io.Copy(w, strings.NewReader("test"))
+	if err != nil {
+		t.Fatalf("Copy() error: %s", err)
+	}
+	if buf.String() != "test" {
+		t.Errorf("underlying writer got %q, want %q", buf.String(), "test")
+	}
+	if w.N() != 4 {
+		t.Errorf("N() = %d, want 4", w.N())
+	}
+	if w.Sum() != "098f6bcd4621d373cade4e832627b4f6" {
+		t.Errorf("Sum() = %v, want %v", w.Sum(), "098f6bcd4621d373cade4e832627b4f6")
+	}
+
+	// Only what the underlying writer accepted should be hashed
+	var short = &shortWriter{limit: 2}
+	w = NewWriter(short, NewMD5())
+	_, err = w.Write([]byte("test"))
+	if err != io.ErrShortWrite {
+		t.Errorf("Write() error = %v, want %v", err, io.ErrShortWrite)
+	}
+	if w.N() != 2 {
+		t.Errorf("N() = %d, want 2", w.N())
+	}
+	var expected = NewMD5().Sum(strings.NewReader("te"))
+	if w.Sum() != expected {
+		t.Errorf("Sum() = %v, want %v", w.Sum(), expected)
+	}
+}